@@ -0,0 +1,115 @@
+package fcache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// 达到maxEntries上限时决定新key能否替换淘汰候选, 只对EvictOnFull策略生效
+// 方法会在持有shard锁时被调用, 实现需要自行保证并发安全, 且不能读写cache
+type Admission interface {
+	// 每次读取key时调用, 无论是否命中
+	Record(k string)
+	// 返回false时拒绝写入candidate, victim保留
+	Admit(candidate, victim string) bool
+}
+
+// 基于Count-Min Sketch估算访问频率, 新key的频率不低于淘汰候选时才写入
+// 只被访问一次的key不会挤掉热点key
+type TinyLFU struct {
+	mu      sync.Mutex
+	rows    [4][]uint8
+	mask    uint64
+	adds    int
+	resetAt int
+}
+
+// capacity为预期的条目数, 通常与maxEntries相同; 每行计数器的数量为capacity的8倍
+func NewTinyLFU(capacity int) *TinyLFU {
+	if capacity < 16 {
+		capacity = 16
+	}
+	width := 1
+	for width < 8*capacity {
+		width <<= 1
+	}
+	t := &TinyLFU{mask: uint64(width - 1), resetAt: 10 * capacity}
+	for i := range t.rows {
+		t.rows[i] = make([]uint8, width)
+	}
+	return t
+}
+
+// 计数上限为15; 累计记录次数达到10倍capacity时所有计数减半, 使旧的热点逐渐失效
+func (t *TinyLFU) Record(k string) {
+	h := hashKey(k)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.rows {
+		if c := &t.rows[i][t.index(h, i)]; *c < 15 {
+			*c++
+		}
+	}
+	if t.adds++; t.adds >= t.resetAt {
+		for _, row := range t.rows {
+			for j := range row {
+				row[j] >>= 1
+			}
+		}
+		t.adds /= 2
+	}
+}
+
+func (t *TinyLFU) Admit(candidate, victim string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.estimate(hashKey(candidate)) >= t.estimate(hashKey(victim))
+}
+
+// 估算的访问次数
+func (t *TinyLFU) Estimate(k string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return int(t.estimate(hashKey(k)))
+}
+
+func (t *TinyLFU) estimate(h uint64) uint8 {
+	min := uint8(15)
+	for i := range t.rows {
+		if c := t.rows[i][t.index(h, i)]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// 双重哈希得到每一行的位置
+func (t *TinyLFU) index(h uint64, row int) uint64 {
+	return (h + uint64(row)*(h>>32|1)) & t.mask
+}
+
+// 返回下一个会被淘汰的key, 调用时需持有写锁
+func (s *shard) victim(skip string) (string, bool) {
+	var victim string
+	found := false
+	s.policy.eachOldest(func(k string) bool {
+		if k == skip || s.refs[k] > 0 || s.items[k].pinned {
+			return true
+		}
+		victim, found = k, true
+		return false
+	})
+	return victim, found
+}
+
+// 调用时需持有写锁
+func (s *shard) admit(k string) error {
+	adm := s.c.conf().admission
+	if adm == nil {
+		return nil
+	}
+	if victim, ok := s.victim(k); ok && !adm.Admit(k, victim) {
+		return fmt.Errorf("%w: %s not admitted", ErrCacheFull, k)
+	}
+	return nil
+}
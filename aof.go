@@ -0,0 +1,335 @@
+package fcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 日志格式: magic "FCAO" | version uint16, 之后每个写操作一帧(见writeFrame)
+const (
+	aofMagic   = "FCAO"
+	aofVersion = 1
+	aofHeader  = int64(len(aofMagic) + 2)
+	// 日志小于该大小时不重写
+	aofMinRewrite = 64 << 20
+)
+
+type AppendSync int
+
+const (
+	// 每秒刷盘一次, 崩溃时最多丢失约1秒的写入
+	SyncEverySecond AppendSync = iota
+	// 每次写入都刷盘
+	SyncAlways
+	// 只写入操作系统缓冲区, 由操作系统决定何时落盘
+	SyncNever
+)
+
+const (
+	aofSet byte = iota
+	aofDelete
+	aofFlush
+)
+
+type aofRecord struct {
+	Op   byte
+	Key  string
+	Item Item
+}
+
+type appendLog struct {
+	c    *Cache
+	path string
+	sync AppendSync
+	mu   sync.Mutex
+	f    *os.File
+	w    *bufio.Writer
+	size int64
+	base int64
+	buf  bytes.Buffer
+	// 重写期间的新写入同时记录在rewrite中, 重写完成后追加到新日志
+	rewrite *bytes.Buffer
+	err     error
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// 回放path中的日志, 之后的每次写入和删除都追加到该日志
+// 需在缓存开始使用之前调用, 回放的条目会覆盖缓存中已有的同名key
+// 日志文件只允许所有者读写, 设置了Cipher时每条记录单独加密; 含有加密记录的日志需先设置Cipher才能回放
+func (c *Cache) EnableAppendLog(path string, sync AppendSync) error {
+	if c.closed() {
+		return ErrClosed
+	}
+	if c.aof.Load() != nil {
+		return fmt.Errorf("Append log already enabled")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	size, err := c.replayAppendLog(f)
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if err == nil {
+		_, err = f.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a := &appendLog{
+		c:    c,
+		path: path,
+		sync: sync,
+		f:    f,
+		w:    bufio.NewWriter(f),
+		size: size,
+		base: size,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	c.aof.Store(a)
+	go a.loop()
+	return nil
+}
+
+// 返回最后一条完整记录的结尾位置, 其后被截断的内容会在追加前丢弃
+func (c *Cache) replayAppendLog(f *os.File) (int64, error) {
+	r := bufio.NewReader(f)
+	magic := make([]byte, aofHeader)
+	n, err := io.ReadFull(r, magic)
+	if n == 0 && err == io.EOF {
+		if _, err = f.Write(append([]byte(aofMagic), 0, aofVersion)); err != nil {
+			return 0, err
+		}
+		return aofHeader, nil
+	}
+	if err != nil || string(magic[:len(aofMagic)]) != aofMagic || magic[len(aofMagic)] != 0 || magic[len(aofMagic)+1] != aofVersion {
+		return 0, fmt.Errorf("%w: bad append log header", ErrIncompatibleSnapshot)
+	}
+	offset := aofHeader
+	for {
+		payload, valid, err := readFrame(r)
+		if err != nil {
+			break
+		}
+		offset += frameSize(payload)
+		if !valid {
+			continue
+		}
+		payload, err = c.openRecord(payload)
+		if errors.Is(err, ErrIncompatibleSnapshot) {
+			return 0, err
+		}
+		var rec aofRecord
+		if err != nil || gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec) != nil {
+			continue
+		}
+		c.apply(rec)
+	}
+	c.shrink()
+	return offset, nil
+}
+
+func (c *Cache) apply(rec aofRecord) {
+	if rec.Op == aofFlush {
+		for _, s := range c.shards {
+			s.mu.Lock()
+			s.flush()
+			s.unlock()
+		}
+		return
+	}
+	s := c.shard(rec.Key)
+	s.mu.Lock()
+	switch {
+	case rec.Op == aofDelete:
+		s.delete(rec.Key, Deleted)
+	case c.expired(rec.Item):
+		// 已过期的记录直接丢弃, 被它覆盖的旧值也不触发删除回调
+		s.quiet = true
+		s.delete(rec.Key, Expired)
+		s.quiet = false
+	default:
+		s.store(rec.Key, rec.Item)
+	}
+	s.unlock()
+}
+
+// 调用时需持有对应shard的写锁, 保证同一key的日志顺序与内存中一致
+func (a *appendLog) append(op byte, k string, item Item) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	if op == aofSet {
+		item = item.unpacked()
+		register(item.Object)
+	}
+	a.buf.Reset()
+	if err := gob.NewEncoder(&a.buf).Encode(&aofRecord{Op: op, Key: k, Item: item}); err != nil {
+		a.fail(err)
+		return
+	}
+	payload, err := a.c.sealRecord(a.buf.Bytes())
+	if err != nil {
+		a.fail(err)
+		return
+	}
+	if err := writeFrame(a.w, payload); err != nil {
+		a.fail(err)
+		return
+	}
+	if a.rewrite != nil {
+		writeFrame(a.rewrite, payload)
+	}
+	a.size += frameSize(payload)
+	if a.sync == SyncAlways {
+		a.flush()
+	}
+}
+
+// 调用时需持有a.mu
+func (a *appendLog) flush() {
+	err := a.w.Flush()
+	if err == nil && a.sync != SyncNever {
+		err = a.f.Sync()
+	}
+	if err != nil {
+		a.fail(err)
+	}
+}
+
+// 调用时需持有a.mu, 错误在Close时返回
+func (a *appendLog) fail(err error) {
+	a.err = err
+	a.c.warn("fcache: append log write failed", "path", a.path, "err", err)
+}
+
+func (a *appendLog) loop() {
+	defer close(a.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.Lock()
+			a.flush()
+			grow := a.rewrite == nil && a.size >= aofMinRewrite && a.size >= 2*a.base
+			a.mu.Unlock()
+			if grow {
+				a.c.RewriteAppendLog()
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// 以当前缓存内容重写日志, 丢弃已被覆盖或删除的历史记录
+// 日志达到64MB且为上次重写后的两倍时会在后台自动重写
+func (c *Cache) RewriteAppendLog() error {
+	a := c.aof.Load()
+	if a == nil {
+		return fmt.Errorf("Append log not enabled")
+	}
+	a.mu.Lock()
+	if a.closed || a.rewrite != nil {
+		a.mu.Unlock()
+		return nil
+	}
+	a.rewrite = &bytes.Buffer{}
+	a.mu.Unlock()
+
+	// 先开始记录新写入再取快照, 回放时新写入覆盖快照中的旧值
+	items := c.Items()
+	f, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".rewrite*")
+	if err == nil {
+		err = c.writeAppendLog(f, items)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pending := a.rewrite
+	a.rewrite = nil
+	if err != nil {
+		if f != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+		return err
+	}
+	if a.closed {
+		f.Close()
+		os.Remove(f.Name())
+		return nil
+	}
+	_, err = f.Write(pending.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), a.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	a.f.Close()
+	size, _ := f.Seek(0, io.SeekEnd)
+	a.f = f
+	a.w = bufio.NewWriter(f)
+	a.size = size
+	a.base = size
+	return nil
+}
+
+func (c *Cache) writeAppendLog(f *os.File, items map[string]Item) error {
+	w := bufio.NewWriter(f)
+	w.Write(append([]byte(aofMagic), 0, aofVersion))
+	var buf bytes.Buffer
+	for k, v := range items {
+		register(v.Object)
+		buf.Reset()
+		if err := gob.NewEncoder(&buf).Encode(&aofRecord{Op: aofSet, Key: k, Item: v}); err != nil {
+			return err
+		}
+		payload, err := c.sealRecord(buf.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := writeFrame(w, payload); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (a *appendLog) close() error {
+	close(a.stop)
+	<-a.done
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	a.w.Flush()
+	if err := a.f.Sync(); err != nil && a.err == nil {
+		a.err = err
+	}
+	if err := a.f.Close(); err != nil && a.err == nil {
+		a.err = err
+	}
+	return a.err
+}
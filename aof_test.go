@@ -0,0 +1,103 @@
+package fcache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAppendLogCipher(t *testing.T) {
+	ci, err := NewAESCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "aof")
+	c := New(WithGCInterval(0))
+	c.SetCipher(ci)
+	if err := c.EnableAppendLog(path, SyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("k", "secret-payload", NoExpiration)
+	if err := c.RewriteAppendLog(); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("k2", "secret-payload", NoExpiration)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret-payload")) {
+		t.Error("value stored in plaintext")
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v, want 0600", fi.Mode().Perm())
+	}
+
+	tests := []struct {
+		name    string
+		cipher  Cipher
+		wantErr error
+	}{
+		{"with cipher", ci, nil},
+		{"without cipher", nil, ErrIncompatibleSnapshot},
+	}
+	for _, tt := range tests {
+		c := New(WithGCInterval(0))
+		c.SetCipher(tt.cipher)
+		err := c.EnableAppendLog(path, SyncAlways)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil {
+			for _, k := range []string{"k", "k2"} {
+				if v, ok := c.Get(k); !ok || v != "secret-payload" {
+					t.Errorf("%s: Get(%s) = %v, %v", tt.name, k, v, ok)
+				}
+			}
+		}
+		c.Close()
+	}
+}
+
+// 回放时丢弃过期记录, 不触发删除回调
+func TestAppendLogReplayDropsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aof")
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithClock(clock), WithGCInterval(0))
+	if err := c.EnableAppendLog(path, SyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("k", 1, NoExpiration)
+	c.Set("k", 2, time.Second)
+	c.Set("live", 1, NoExpiration)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Second)
+
+	var removed []string
+	c = New(WithClock(clock), WithGCInterval(0))
+	c.OnRemoved(func(k string, v interface{}, reason Reason) { removed = append(removed, k) })
+	if err := c.EnableAppendLog(path, SyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if len(removed) != 0 {
+		t.Errorf("OnRemoved called for %v during replay", removed)
+	}
+	if _, ok := c.Get("k"); ok {
+		t.Error("expired record replayed")
+	}
+	if _, ok := c.Get("live"); !ok {
+		t.Error("live record lost")
+	}
+}
@@ -0,0 +1,78 @@
+package fcache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type autoSaver struct {
+	path     string
+	interval time.Duration
+	mu       sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (c *Cache) startAutoSave(path string, interval time.Duration) {
+	// 快照不存在时忽略, 部分损坏时保留已加载的条目
+	c.LoadSnapshotFromFile(path)
+	a := &autoSaver{
+		path:     path,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	c.autoSave = a
+	if interval <= 0 {
+		close(a.done)
+		return
+	}
+	go c.autoSaveLoop(a)
+}
+
+func (c *Cache) autoSaveLoop(a *autoSaver) {
+	defer close(a.done)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.saveSnapshotAtomic(a); err != nil {
+				c.warn("fcache: auto save failed", "path", a.path, "err", err)
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// 先写临时文件再重命名, 保存中途崩溃不会破坏上一份快照
+func (c *Cache) saveSnapshotAtomic(a *autoSaver) (err error) {
+	_, span := c.startSpan(context.Background(), "fcache.autosave", attribute.String("fcache.file", a.path))
+	defer func() { endSpan(span, err) }()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.CreateTemp(filepath.Dir(a.path), filepath.Base(a.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err = c.encryptTo(f, c.SaveSnapshot); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, a.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// 按shard对key分组
+func (c *Cache) groupKeys(keys []string) map[*shard][]string {
+	groups := map[*shard][]string{}
+	for _, k := range keys {
+		s := c.shard(k)
+		groups[s] = append(groups[s], k)
+	}
+	return groups
+}
+
+// 批量写入, 每个shard只加一次锁; 返回第一个写入失败的错误, 其余key照常写入
+func (c *Cache) SetMulti(items map[string]interface{}, d time.Duration) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	return c.setMulti(items, d)
+}
+
+// 不经checkTTL和skipStore的SetMulti
+func (c *Cache) setMulti(items map[string]interface{}, d time.Duration) error {
+	keys := make([]string, 0, len(items))
+	ttls := make(map[string]time.Duration, len(items))
+	for k := range items {
+		keys = append(keys, k)
+		ttls[k] = c.inheritTTL(k, d)
+	}
+	var firstErr error
+	for s, group := range c.groupKeys(keys) {
+		s.mu.Lock()
+		for _, k := range group {
+			if err := s.waitSpace(context.Background(), k); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			s.set(k, items[k], ttls[k])
+		}
+		s.unlock()
+	}
+	c.shrink()
+	return firstErr
+}
+
+// 批量读取, 只返回命中的key
+func (c *Cache) GetMulti(keys []string) map[string]interface{} {
+	found := make(map[string]interface{}, len(keys))
+	for s, group := range c.groupKeys(keys) {
+		s.mu.Lock()
+		for _, k := range group {
+			v, ok := s.get(k)
+			c.recordGet(ok)
+			if ok {
+				found[k] = v
+			}
+		}
+		s.unlock()
+	}
+	return found
+}
+
+// 先批量读取, 再以未命中的key调用一次loader, loader返回的值以默认过期时间写入后与命中的值一起返回
+// loader没有返回的key视为不存在; loader返回错误时不写入, 返回命中的部分和该错误
+// 与SetWithDefaultTTL一样不受strictTTL和zeroNoStore影响
+func (c *Cache) GetMultiOrLoad(ctx context.Context, keys []string, loader func(ctx context.Context, missing []string) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	found := c.GetMulti(keys)
+	var missing []string
+	seen := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		if _, ok := found[k]; ok {
+			continue
+		}
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+	loaded, err := loader(ctx, missing)
+	if err != nil {
+		return found, err
+	}
+	fill := make(map[string]interface{}, len(loaded))
+	for _, k := range missing {
+		if v, ok := loaded[k]; ok {
+			fill[k] = v
+			found[k] = v
+		}
+	}
+	if err := c.setMulti(fill, DefaultExpiration); err != nil && !errors.Is(err, ErrReadOnly) {
+		return found, err
+	}
+	return found, nil
+}
+
+func (c *Cache) DeleteMulti(keys []string) {
+	if c.writable() != nil {
+		return
+	}
+	for s, group := range c.groupKeys(keys) {
+		s.mu.Lock()
+		for _, k := range group {
+			if s.deleteKey(k) {
+				atomic.AddUint64(&c.stats.deletes, 1)
+			}
+		}
+		s.unlock()
+	}
+}
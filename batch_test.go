@@ -0,0 +1,45 @@
+package fcache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 加载的值以默认过期时间写入, 不受strictTTL和zeroNoStore影响
+func TestGetMultiOrLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"default", WithDefaultTTL(time.Minute)},
+		{"strict ttl", WithStrictTTL()},
+		{"zero no store", WithZeroDurationNoStore()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			c := New(WithClock(clock), WithGCInterval(0), WithDefaultTTL(time.Minute), tt.opt)
+			c.SetWithDefaultTTL("hit", 0)
+			var asked [][]string
+			loader := func(ctx context.Context, missing []string) (map[string]interface{}, error) {
+				asked = append(asked, missing)
+				return map[string]interface{}{"a": 1, "b": 2}, nil
+			}
+			want := map[string]interface{}{"hit": 0, "a": 1, "b": 2}
+			for i := 0; i < 2; i++ {
+				got, err := c.GetMultiOrLoad(context.Background(), []string{"hit", "a", "b", "none", "a"}, loader)
+				if err != nil || !reflect.DeepEqual(got, want) {
+					t.Fatalf("call %d: got %v, %v; want %v", i, got, err, want)
+				}
+			}
+			if want := [][]string{{"a", "b", "none"}, {"none"}}; !reflect.DeepEqual(asked, want) {
+				t.Fatalf("loader asked for %v, want %v", asked, want)
+			}
+			if d, ok := c.TTL("a"); !ok || d != time.Minute {
+				t.Fatalf("TTL(a) = %v, %v; want the default", d, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package fcache
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// 记录写入过的key, 用于判断key一定没有写入过
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n <= 0 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Ceil(math.Ln2 * float64(m) / float64(n)))
+	if k == 0 {
+		k = 1
+	}
+	return &bloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// 双重哈希生成k个位置
+func (b *bloomFilter) hash(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31
+	return h1, h2 | 1
+}
+
+func (b *bloomFilter) add(key string) {
+	h1, h2 := b.hash(key)
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+func (b *bloomFilter) mayContain(key string) bool {
+	h1, h2 := b.hash(key)
+	for i := uint64(0); i < b.k; i++ {
+		pos := (h1 + i*h2) % b.m
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// 开启key过滤器, expectedItems为预期key数量, fpRate为误判率
+// 开启前已写入的key会被加入过滤器
+func (c *Cache) EnableKeyFilter(expectedItems int, fpRate float64) {
+	n := expectedItems/len(c.shards) + 1
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.filter = newBloomFilter(n, fpRate)
+		for k := range s.items {
+			s.filter.add(k)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// 返回false表示key一定没有写入过; 未开启过滤器时总是返回true
+func (c *Cache) MayContain(k string) bool {
+	s := c.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.filter == nil {
+		return true
+	}
+	return s.filter.mayContain(k)
+}
@@ -0,0 +1,38 @@
+package fcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKeyFilterSkipsLoader(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	loads := map[string]int{}
+	c := New(WithClock(clock), WithGCInterval(0), WithShards(4), WithLoader(func(ctx context.Context, k string) (interface{}, time.Duration, error) {
+		loads[k]++
+		return "loaded", time.Hour, nil
+	}))
+	c.Set("before", 1, time.Second)
+	c.EnableKeyFilter(100, 0.001)
+	c.Set("after", 1, time.Second)
+	clock.Advance(2 * time.Second)
+
+	tests := []struct {
+		key   string
+		loads int
+	}{
+		{"never", 0},
+		{"before", 1},
+		{"after", 1},
+	}
+	for _, tt := range tests {
+		v, ok := c.Get(tt.key)
+		if ok != (tt.loads > 0) || loads[tt.key] != tt.loads {
+			t.Errorf("Get(%s) = %v, %v with %d loads; want %d loads", tt.key, v, ok, loads[tt.key], tt.loads)
+		}
+		if c.MayContain(tt.key) != (tt.loads > 0) {
+			t.Errorf("MayContain(%s) = %v", tt.key, tt.loads == 0)
+		}
+	}
+}
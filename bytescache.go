@@ -0,0 +1,196 @@
+package fcache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 条目头部: 总长度(4) | 过期时间(8) | key的哈希(8) | key长度(2)
+const (
+	entryHeader = 22
+	maxBytesKey = 1<<16 - 1
+)
+
+// 专门存放[]byte的缓存, 条目连续写入每个shard预分配的环形缓冲区, 索引只保存哈希到偏移量的映射
+// map和缓冲区中都没有指针, 条目数量很大时GC不需要扫描它们
+// 缓冲区写满后按写入顺序淘汰最早的条目, 覆盖和删除的旧数据要等到被淘汰时才释放空间
+// 不支持回调, 标签, 持久化等Cache的功能
+type BytesCache struct {
+	shards []*bytesShard
+	clock  Clock
+}
+
+type bytesShard struct {
+	mu    sync.RWMutex
+	index map[uint64]uint32
+	buf   []byte
+	// 未回绕时数据在[head, tail); 回绕后在[head, end)和[0, tail)
+	head, tail, end int
+	wrapped         bool
+	entries         int
+}
+
+// 共占用约shards*shardBytes字节, shardBytes不能超过4GB, 单个条目(含key和22字节的头部)不能超过shardBytes
+func NewBytesCache(shards, shardBytes int) *BytesCache {
+	if shards < 1 {
+		shards = 1
+	}
+	c := &BytesCache{shards: make([]*bytesShard, shards), clock: realClock{}}
+	for i := range c.shards {
+		c.shards[i] = &bytesShard{index: map[uint64]uint32{}, buf: make([]byte, shardBytes)}
+	}
+	return c
+}
+
+// 替换过期判断使用的时钟, 需在使用前调用
+func (c *BytesCache) SetClock(clock Clock) {
+	c.clock = clock
+}
+
+func (c *BytesCache) shard(h uint64) *bytesShard {
+	return c.shards[h%uint64(len(c.shards))]
+}
+
+// fnv-1a
+func hashKey(k string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(k); i++ {
+		h ^= uint64(k[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// d小于等于0表示永不过期, v会被复制
+func (c *BytesCache) Set(k string, v []byte, d time.Duration) error {
+	if len(k) > maxBytesKey {
+		return fmt.Errorf("Key %s is too long", k)
+	}
+	h := hashKey(k)
+	s := c.shard(h)
+	n := entryHeader + len(k) + len(v)
+	if n > len(s.buf) {
+		return fmt.Errorf("%w: entry %s of %d bytes exceeds shard size %d", ErrCacheFull, k, n, len(s.buf))
+	}
+	var exp int64
+	if d > 0 {
+		exp = c.clock.Now().Add(d).UnixNano()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	off := s.alloc(n)
+	e := s.buf[off : off+n]
+	binary.LittleEndian.PutUint32(e, uint32(n))
+	binary.LittleEndian.PutUint64(e[4:], uint64(exp))
+	binary.LittleEndian.PutUint64(e[12:], h)
+	binary.LittleEndian.PutUint16(e[20:], uint16(len(k)))
+	copy(e[entryHeader:], k)
+	copy(e[entryHeader+len(k):], v)
+	s.index[h] = uint32(off)
+	s.entries++
+	return nil
+}
+
+// 返回值的副本; 哈希冲突的另一个key会被当作未命中
+func (c *BytesCache) Get(k string) ([]byte, bool) {
+	h := hashKey(k)
+	s := c.shard(h)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.lookup(h, k, c.clock.Now().UnixNano())
+	if !ok {
+		return nil, false
+	}
+	v := e[entryHeader+len(k):]
+	return append(make([]byte, 0, len(v)), v...), true
+}
+
+func (c *BytesCache) Delete(k string) bool {
+	h := hashKey(k)
+	s := c.shard(h)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lookup(h, k, c.clock.Now().UnixNano()); !ok {
+		return false
+	}
+	delete(s.index, h)
+	return true
+}
+
+// 包括已过期但还未被淘汰的条目
+func (c *BytesCache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n += len(s.index)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+func (c *BytesCache) Reset() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.index = map[uint64]uint32{}
+		s.head, s.tail, s.end, s.wrapped, s.entries = 0, 0, 0, false, 0
+		s.mu.Unlock()
+	}
+}
+
+// 调用时需持有锁
+func (s *bytesShard) lookup(h uint64, k string, now int64) ([]byte, bool) {
+	off, ok := s.index[h]
+	if !ok {
+		return nil, false
+	}
+	n := int(binary.LittleEndian.Uint32(s.buf[off:]))
+	e := s.buf[off : int(off)+n]
+	if int(binary.LittleEndian.Uint16(e[20:])) != len(k) || string(e[entryHeader:entryHeader+len(k)]) != k {
+		return nil, false
+	}
+	if exp := int64(binary.LittleEndian.Uint64(e[4:])); exp > 0 && now > exp {
+		return nil, false
+	}
+	return e, true
+}
+
+// 分配n字节的连续空间, 空间不足时淘汰最早写入的条目
+func (s *bytesShard) alloc(n int) int {
+	for {
+		if s.entries == 0 {
+			s.head, s.tail, s.end, s.wrapped = 0, 0, 0, false
+		}
+		if !s.wrapped {
+			if len(s.buf)-s.tail >= n {
+				break
+			}
+			// 末尾放不下时从头开始写, [tail, len)留空
+			s.end, s.tail, s.wrapped = s.tail, 0, true
+			continue
+		}
+		if s.head-s.tail >= n {
+			break
+		}
+		s.evict()
+	}
+	off := s.tail
+	s.tail += n
+	return off
+}
+
+func (s *bytesShard) evict() {
+	e := s.buf[s.head:]
+	n := int(binary.LittleEndian.Uint32(e))
+	h := binary.LittleEndian.Uint64(e[12:])
+	// 已被覆盖或删除的条目不在索引中
+	if off, ok := s.index[h]; ok && int(off) == s.head {
+		delete(s.index, h)
+	}
+	s.head += n
+	s.entries--
+	if s.head == s.end {
+		s.head, s.end, s.wrapped = 0, 0, false
+	}
+}
@@ -1,12 +1,17 @@
 package fcache
 
 import (
-	"time"
-	"sync"
+	"context"
+	"encoding/gob"
+	"errors"
 	"fmt"
 	"io"
-	"encoding/gob"
-	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -16,206 +21,951 @@ const (
 	DefaultExpiration time.Duration = 0
 )
 
-type Cache struct {
+// 达到容量上限时的写入策略
+type OverflowPolicy int
+
+const (
+	// 淘汰最久未访问的key
+	EvictOnFull OverflowPolicy = iota
+	// 拒绝写入新的key
+	RejectOnFull
+	// 阻塞写入直到有空间
+	BlockOnFull
+)
+
+// 运行期可修改的配置, 修改时整体替换, 读取时无需加锁
+type config struct {
 	defaultExpiration time.Duration
-	items             map[string]Item
-	mu                sync.RWMutex
-	gcInterval        time.Duration
-	stopGc            chan bool
+	maxEntries        int
+	maxMemory         int64
+	maxCost           int64
+	sizeFunc          func(k string, v interface{}) int64
+	trackMemory       bool
+	overflow          OverflowPolicy
+	strictSave        bool
+	keyDelimiter      string
+	zeroNoStore       bool
+	strictTTL         bool
+	lazyExpire        bool
+	archive           func(string, Item) error
+	archiveRetain     bool
+	onEvicted         func(string, interface{})
+	onRemoved         func(string, interface{}, Reason)
+	loader            LoaderFunc
+	negativeTTL       time.Duration
+	staleWindow       time.Duration
+	ttlJitter         float64
+	compressMin       int
+	cipher            Cipher
+	codec             Codec
+	sliding           bool
+	maxTTL            time.Duration
+	maxLifetime       time.Duration
+	copyOnRead        bool
+	cloner            func(interface{}) interface{}
+	admission         Admission
+	gcShards          int
+	softLimit         uint64
+	onShed            func([]string, uint64)
+	logger            Logger
+	evictWarn         int
+	tracer            trace.Tracer
+	readOnly          bool
+	earlyBeta         float64
+	changeLog         time.Duration
+}
+
+type Cache struct {
+	shards   []*shard
+	cfg      atomic.Pointer[config]
+	cfgMu    sync.Mutex
+	count    int64
+	memUsage int64
+	cost     int64
+	// 被Pin固定的条目数
+	pinned int64
+	// 最近一次分配的版本号, 见Item.Version
+	version uint64
+	// 写入序号和已丢弃的删除记录中最大的序号, 见Changes
+	seq        uint64
+	pruned     uint64
+	spaceMu    sync.Mutex
+	space      chan struct{}
+	waiters    int32
+	flight     flightGroup
+	negative   negativeCache
+	refreshing sync.Map
+	watch      watchers
+	stats      stats
+	policy     EvictionPolicy
+	gcInterval time.Duration
+	stopGc     chan bool
+	gcPaused   int32
+	shedding   int32
+	gcNext     int
+	// 见WithLockFreeReads
+	lockFreeReads bool
+	indexes       map[string]IndexFunc
+	prefixes      []*prefixRule
+	// 有前缀规则设置了MaxMemory
+	prefixMemory bool
+	clock        Clock
+	keyMu        KeyMutex
+	deps         depGraph
+	gcOnce       sync.Once
+	autoSave     *autoSaver
+	aof          atomic.Pointer[appendLog]
+	disk         *diskStore
+	mapped       *mappedSnapshot
+	diskErr      error
+	isClosed     int32
+	closeOnce    sync.Once
+	closeDone    chan struct{}
+	closeErr     error
+}
+
+func (c *Cache) conf() *config {
+	return c.cfg.Load()
+}
+
+// 复制当前配置修改后整体替换
+func (c *Cache) configure(f func(cfg *config)) {
+	c.cfgMu.Lock()
+	defer c.cfgMu.Unlock()
+	cfg := *c.conf()
+	f(&cfg)
+	c.cfg.Store(&cfg)
+}
+
+func (c *Cache) shard(k string) *shard {
+	return c.shards[c.shardIndex(k)]
+}
+
+func (c *Cache) shardIndex(k string) int {
+	if len(c.shards) == 1 {
+		return 0
+	}
+	// fnv-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(k); i++ {
+		h ^= uint32(k[i])
+		h *= 16777619
+	}
+	return int(h % uint32(len(c.shards)))
+}
+
+// 按shard对条目分组
+func (c *Cache) groupItems(items map[string]Item) map[*shard]map[string]Item {
+	groups := map[*shard]map[string]Item{}
+	for k, v := range items {
+		s := c.shard(k)
+		if groups[s] == nil {
+			groups[s] = map[string]Item{}
+		}
+		groups[s][k] = v
+	}
+	return groups
 }
 
 func (c *Cache) gcLoop() {
-	ticker := time.NewTicker(c.gcInterval)
+	ticker := c.clock.NewTicker(c.gcInterval)
+	evictions := c.Stats().Evictions
 	for {
 		select {
-		case <-ticker.C:
-			c.DeleteExpired()
+		case <-ticker.C():
+			if atomic.LoadInt32(&c.gcPaused) == 0 {
+				c.sweep(c.conf().gcShards)
+			}
+			evictions = c.checkEvictions(evictions, c.gcInterval)
 		case <-c.stopGc:
 			ticker.Stop()
 			return
 		}
 	}
 }
+
+// 从上次停下的shard开始清理n个shard, 只在gcLoop中调用
+func (c *Cache) sweep(n int) {
+	if n <= 0 || n >= len(c.shards) {
+		c.RunGC()
+		return
+	}
+	start := time.Now()
+	defer c.recordGC(start)
+	if c.gcNext == 0 {
+		c.negative.deleteExpired(c.nowNano())
+	}
+	removed, from := 0, c.gcNext
+	for i := 0; i < n; i++ {
+		removed += len(c.shards[c.gcNext].deleteExpired())
+		c.gcNext = (c.gcNext + 1) % len(c.shards)
+	}
+	c.debug("fcache: gc sweep", "removed", removed, "shards", n, "from", from, "duration", time.Since(start))
+}
+
 func (c *Cache) DeleteExpired() {
-	now := time.Now().UnixNano()
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.RunGC()
+}
 
-	for k, v := range c.items {
-		if v.Expiration > 0 && now > v.Expiration {
-			c.delete(k)
-		}
+// 立即清理一次过期条目, 返回删除的数量; 暂停后台清理时也会执行
+func (c *Cache) RunGC() int {
+	start := time.Now()
+	defer c.recordGC(start)
+	c.negative.deleteExpired(c.nowNano())
+	n := 0
+	for _, s := range c.shards {
+		n += len(s.deleteExpired())
 	}
+	c.debug("fcache: gc sweep", "removed", n, "duration", time.Since(start))
+	return n
 }
 
-func (c *Cache) delete(k string) {
-	delete(c.items, k)
+// 与DeleteExpired相同, 返回本次删除的条目
+func (c *Cache) DeleteExpiredCollect() []Entry {
+	defer c.recordGC(time.Now())
+	c.negative.deleteExpired(c.nowNano())
+	var removed []Entry
+	for _, s := range c.shards {
+		removed = append(removed, s.deleteExpired()...)
+	}
+	for i := range removed {
+		removed[i].Item = removed[i].Item.unpacked()
+	}
+	return removed
 }
 
-func (c *Cache) set(k string, v interface{}, d time.Duration) {
-	var e int64
-	if d == DefaultExpiration {
-		d = c.defaultExpiration
+// 唤醒等待空间的写入
+func (c *Cache) notifySpace() {
+	if atomic.LoadInt32(&c.waiters) == 0 {
+		return
 	}
-	if d > 0 {
-		e = time.Now().Add(d).UnixNano()
+	c.spaceMu.Lock()
+	close(c.space)
+	c.space = make(chan struct{})
+	c.spaceMu.Unlock()
+}
+
+// 登记为等待者并返回空间释放的通知channel
+func (c *Cache) waitSpace() <-chan struct{} {
+	c.spaceMu.Lock()
+	defer c.spaceMu.Unlock()
+	atomic.AddInt32(&c.waiters, 1)
+	return c.space
+}
+
+func (c *Cache) doneWaiting() {
+	atomic.AddInt32(&c.waiters, -1)
+}
+
+// 以DefaultExpiration写入且设置了分隔符时, 继承最近的有效祖先key的剩余存活时间
+// 祖先key可能在其他shard, 需在加锁前调用
+func (c *Cache) inheritTTL(k string, d time.Duration) time.Duration {
+	if d != DefaultExpiration {
+		return d
 	}
-	c.items[k] = Item{
-		Object:     v,
-		Expiration: e,
+	delim := c.conf().keyDelimiter
+	if delim == "" {
+		return c.prefixTTL(k, d)
 	}
+	for i := strings.LastIndex(k, delim); i > 0; i = strings.LastIndex(k, delim) {
+		k = k[:i]
+		s := c.shard(k)
+		s.mu.RLock()
+		item, ok := s.items[k]
+		s.mu.RUnlock()
+		if !ok || c.expired(item) {
+			continue
+		}
+		if item.Expiration == 0 {
+			return NoExpiration
+		}
+		if left := c.remaining(item.Expiration); left > 0 {
+			return left
+		}
+	}
+	return c.prefixTTL(k, d)
+}
+
+// 匹配的前缀规则设置了默认过期时间时使用该时间
+func (c *Cache) prefixTTL(k string, d time.Duration) time.Duration {
+	if r := c.ruleFor(k); r != nil && r.DefaultTTL != 0 {
+		return r.DefaultTTL
+	}
+	return d
 }
 
+// 开启strictTTL时, 0和NoExpiration以外的负数不再被解释为默认过期时间
+func (c *Cache) checkTTL(d time.Duration) error {
+	if c.conf().strictTTL && (d == DefaultExpiration || (d < 0 && d != NoExpiration)) {
+		return fmt.Errorf("%w: %v, use SetWithDefaultTTL or SetForever", ErrAmbiguousTTL, d)
+	}
+	return nil
+}
+
+// 开启zeroNoStore时, 字面量0表示不缓存而不是使用默认过期时间
+func (c *Cache) skipStore(d time.Duration) bool {
+	return c.conf().zeroNoStore && d == 0
+}
+
+// 达到容量上限时, 默认淘汰最久未访问的key; RejectOnFull策略下新key会被丢弃, BlockOnFull策略下会一直阻塞
+// 缓存已关闭或只读时不写入并通过Logger记录, 需要错误时使用SetCtx
 func (c *Cache) Set(k string, v interface{}, d time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.set(k, v, d)
+	c.rejected(k, c.SetCtx(context.Background(), k, v, d))
 }
 
-func (c *Cache) Add(k string, v interface{}, d time.Duration) error {
-	c.mu.Lock()
-	_, ok := c.get(k)
-	if ok {
-		c.mu.Unlock()
-		return fmt.Errorf("Item % s already exists", k)
+// 记录不返回错误的写入方法因关闭或只读被拒绝的写入
+func (c *Cache) rejected(k string, err error) {
+	if errors.Is(err, ErrClosed) || errors.Is(err, ErrReadOnly) {
+		c.warn("fcache: write rejected", "key", k, "err", err)
 	}
-	c.set(k, v, d)
-	c.mu.Unlock()
+}
+
+// 与Set相同, 但返回拒绝写入的错误, 阻塞等待时可通过ctx取消
+func (c *Cache) SetCtx(ctx context.Context, k string, v interface{}, d time.Duration) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	return c.set(ctx, k, v, d)
+}
+
+// 明确使用默认过期时间, 不受strictTTL和zeroNoStore影响
+func (c *Cache) SetWithDefaultTTL(k string, v interface{}) {
+	c.rejected(k, c.set(context.Background(), k, v, DefaultExpiration))
+}
+
+// 永不过期
+func (c *Cache) SetForever(k string, v interface{}) {
+	c.rejected(k, c.set(context.Background(), k, v, NoExpiration))
+}
+
+// 与Set相同, 只对这个条目开启滑动过期, 每次读取时按存活时间延长过期时间
+func (c *Cache) SetSliding(k string, v interface{}, d time.Duration) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	return c.setItem(context.Background(), k, Item{Object: v, Sliding: true}, d)
+}
+
+func (c *Cache) set(ctx context.Context, k string, v interface{}, d time.Duration) error {
+	return c.setItem(ctx, k, Item{Object: v}, d)
+}
+
+func (c *Cache) setItem(ctx context.Context, k string, item Item, d time.Duration) error {
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	if err := s.waitSpace(ctx, k); err != nil {
+		s.unlock()
+		return err
+	}
+	s.setItem(k, item, d)
+	s.unlock()
+	c.shrink()
 	return nil
 }
 
+func (c *Cache) Add(k string, v interface{}, d time.Duration) error {
+	_, _, err := c.SetNX(k, v, d)
+	return err
+}
+
+// key不存在时写入; 已存在时返回当前值, 剩余存活时间和包装了ErrKeyExists的错误
+// 没有过期时间时剩余存活时间为NoExpiration
+func (c *Cache) SetNX(k string, v interface{}, d time.Duration) (interface{}, time.Duration, error) {
+	if err := c.checkTTL(d); err != nil {
+		return nil, 0, err
+	}
+	if c.skipStore(d) {
+		return nil, 0, nil
+	}
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	if err := s.waitSpace(context.Background(), k); err != nil {
+		s.unlock()
+		return nil, 0, err
+	}
+	if cur, ok := s.get(k); ok {
+		left := NoExpiration
+		if e := s.items[k].Expiration; e > 0 {
+			left = s.c.remaining(e)
+		}
+		s.unlock()
+		return cur, left, fmt.Errorf("%w: %s", ErrKeyExists, k)
+	}
+	s.set(k, v, d)
+	s.unlock()
+	c.shrink()
+	return nil, 0, nil
+}
+
+// 设置了loader时, 未命中会调用loader加载并写入
+func (c *Cache) Get(k string) (interface{}, bool) {
+	v, ok, err := c.GetCtx(context.Background(), k)
+	return v, ok && err == nil
+}
+
+// 与Get相同, 返回loader的错误; ctx结束时不再等待加载并返回ctx的错误
+func (c *Cache) GetCtx(ctx context.Context, k string) (interface{}, bool, error) {
+	v, ok, _, err := c.lookup(ctx, k)
+	return v, ok, err
+}
+
+// early表示k存在但按提前过期被视为未命中
+func (c *Cache) lookup(ctx context.Context, k string) (v interface{}, ok, early bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, false, err
+	}
+	v, ok = c.get(k)
+	old := v
+	if ok && c.expiresEarly(k) {
+		v, ok, early = nil, false, true
+	}
+	c.recordGet(ok)
+	cfg := c.conf()
+	if cfg.loader == nil {
+		return v, ok, early, nil
+	}
+	if !ok {
+		v, ok, err = c.readThrough(ctx, k, early)
+		// 提前加载失败时原值仍未过期
+		if early && err != nil {
+			return old, true, early, nil
+		}
+		return v, ok, early, err
+	}
+	if cfg.staleWindow > 0 {
+		c.refreshAhead(k, cfg.staleWindow)
+	}
+	return v, true, false, nil
+}
+
+// 不计入命中统计
 func (c *Cache) get(k string) (interface{}, bool) {
-	item, ok := c.items[k]
+	s := c.shard(k)
+	if v, ok := s.getFast(k); ok {
+		return v, true
+	}
+	s.mu.Lock()
+	s.readMiss()
+	v, ok := s.get(k)
+	s.unlock()
+	if ok {
+		return v, true
+	}
+	if m := c.mapped; m != nil {
+		if item, found := m.read(k); found {
+			if v, ok := c.hydrate(s, k, item, func() bool { return m.valid(s, k) }); ok {
+				return v, true
+			}
+		}
+	}
+	if d := c.disk; d != nil {
+		if item, found := d.read(k); found {
+			return c.hydrate(s, k, item, nil)
+		}
+	}
+	return nil, false
+}
+
+// 类型不匹配时返回false
+func (c *Cache) GetInt64(k string) (int64, bool) {
+	v, ok := c.Get(k)
 	if !ok {
-		return nil, false
+		return 0, false
 	}
-	if item.Expired() {
-		return nil, false
+	n, ok := v.(int64)
+	return n, ok
+}
+
+func (c *Cache) GetFloat64(k string) (float64, bool) {
+	v, ok := c.Get(k)
+	if !ok {
+		return 0, false
 	}
-	return item.Object, true
+	f, ok := v.(float64)
+	return f, ok
 }
 
-func (c *Cache) Get(k string) (interface{}, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return c.get(k)
+func (c *Cache) GetString(k string) (string, bool) {
+	v, ok := c.Get(k)
+	if !ok {
+		return "", false
+	}
+	str, ok := v.(string)
+	return str, ok
 }
 
 func (c *Cache) Update(k string, v interface{}, d time.Duration) error {
-	c.mu.Lock()
-	_, ok := c.get(k)
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	d = c.inheritTTL(k, d)
+	if err := c.writable(); err != nil {
+		return err
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	_, ok := s.get(k)
 	if !ok {
-		c.mu.Lock()
-		return fmt.Errorf("Item %s doesn't exist", k)
+		s.unlock()
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, k)
 	}
-	c.set(k, v, d)
-	c.mu.Unlock()
+	s.set(k, v, d)
+	s.unlock()
+	c.shrink()
 	return nil
 }
 
 func (c *Cache) Inc(k string, n int64) error {
-	c.mu.Lock()
-	num, ok := c.get(k)
-	if !ok {
-		c.mu.Lock()
-		return fmt.Errorf("Item %s doesn't exist", k)
+	_, err := c.Increment(k, n)
+	return err
+}
+
+// 将other中的数值累加到当前cache, 不存在的key按other中剩余的存活时间写入, 已存在的key保留原过期时间
+// 返回被跳过的key: 非数值, 数值类型无法累加, 或达到容量上限无法写入
+func (c *Cache) MergeIncrement(other *Cache) []string {
+	if c.writable() != nil {
+		return other.Keys()
 	}
-	on, ok := num.(int64)
-	if !ok {
-		return fmt.Errorf("Item %s is not int", n)
+	var skipped []string
+	items := map[string]Item{}
+	for _, o := range other.shards {
+		o.mu.RLock()
+		for k, v := range o.items {
+			if c.expired(v) {
+				continue
+			}
+			if !isNumber(v.Object) {
+				skipped = append(skipped, k)
+				continue
+			}
+			items[k] = v
+		}
+		o.mu.RUnlock()
 	}
-	c.set(k, on+n, DefaultExpiration)
-	c.mu.Unlock()
-	return nil
+
+	for s, group := range c.groupItems(items) {
+		s.mu.Lock()
+		for k, v := range group {
+			item, ok := s.live(k)
+			if !ok {
+				d := NoExpiration
+				if v.Expiration > 0 {
+					if d = c.remaining(v.Expiration); d <= 0 {
+						continue
+					}
+				}
+				// 与其他写入一样受容量上限约束, 版本号由当前cache分配
+				if err := s.waitSpace(context.Background(), k); err != nil {
+					skipped = append(skipped, k)
+					continue
+				}
+				s.set(k, v.Object, d)
+				continue
+			}
+			sum, ok := addNumber(item.Object, v.Object)
+			if !ok {
+				skipped = append(skipped, k)
+				continue
+			}
+			item.Object = sum
+			item.Version = c.nextVersion()
+			s.store(k, item)
+		}
+		s.unlock()
+	}
+	c.shrink()
+	return skipped
+}
+
+// key不存在时以int64(0)初始化, 返回当前值; 已存在的非整数值返回0且不覆盖
+// 只读或RejectOnFull下达到上限时返回错误
+func (c *Cache) EnsureCounter(k string, d time.Duration) (int64, error) {
+	if err := c.writable(); err != nil {
+		return 0, err
+	}
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	defer c.shrink()
+	defer s.unlock()
+	if v, ok := s.get(k); ok {
+		n, _ := toInt64(v)
+		return n, nil
+	}
+	if err := s.waitSpace(context.Background(), k); err != nil {
+		return 0, err
+	}
+	// 等待空间期间可能已被其他goroutine初始化
+	if v, ok := s.get(k); ok {
+		n, _ := toInt64(v)
+		return n, nil
+	}
+	s.set(k, int64(0), d)
+	return 0, nil
 }
 
 func (c *Cache) Delete(k string) {
-	c.mu.Lock()
-	c.delete(k)
-	c.mu.Unlock()
+	if c.writable() != nil {
+		return
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	if s.deleteKey(k) {
+		atomic.AddUint64(&c.stats.deletes, 1)
+	}
+	s.unlock()
+}
+
+// 删除k并返回删除前k是否存在且未过期, 判断和删除在同一个锁内完成, 不调用loader
+func (c *Cache) Remove(k string) bool {
+	if c.writable() != nil {
+		return false
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	ok := c.present(s, k)
+	if s.deleteKey(k) {
+		atomic.AddUint64(&c.stats.deletes, 1)
+	}
+	s.unlock()
+	return ok
+}
+
+// k是否存在且未过期, 不调用loader也不计入命中统计
+func (c *Cache) Exists(k string) bool {
+	s := c.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return c.present(s, k)
+}
+
+// 内存, 内存映射或磁盘中是否有未过期的k, 调用时需持有锁
+func (c *Cache) present(s *shard, k string) bool {
+	if _, ok := s.live(k); ok {
+		return true
+	}
+	if _, ok := s.items[k]; ok {
+		return false
+	}
+	if m := c.mapped; m != nil && m.valid(s, k) {
+		if item, ok := m.read(k); ok && !c.expired(item) {
+			return true
+		}
+	}
+	if d := c.disk; d != nil {
+		if item, ok := d.read(k); ok && !c.expired(item) {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Cache) Save(w io.Writer) (err error) {
-	enc := gob.NewEncoder(w)
+	strict := c.conf().strictSave
 	defer func() {
 		if x := recover(); x != nil {
+			// 调试模式下保留原始的panic及堆栈
+			if strict {
+				panic(x)
+			}
 			err = fmt.Errorf("Error registering item types with Gob library")
 		}
 	}()
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	for _, v := range c.items {
-		gob.Register(v.Object)
-	}
-	err = enc.Encode(&c.items)
-	return
+	// 逐个shard在读锁下复制, 编码在锁外进行, 不阻塞其他读写
+	return c.codec().Encode(w, c.Items())
 }
 
 func (c *Cache) SaveToFile(file string) error {
-	f, err := os.Create(file)
+	return c.saveFile(file, c.Save)
+}
+
+// 已存在且未过期的key不会被覆盖
+func (c *Cache) Load(r io.Reader, opts ...LoadOption) error {
+	if c.closed() {
+		return ErrClosed
+	}
+	r, err := decompressed(r)
 	if err != nil {
 		return err
 	}
-	if err = c.Save(f); err != nil {
-		f.Close()
+	items, err := c.codec().Decode(r)
+	if err != nil {
 		return err
 	}
-	return f.Close()
+	c.load(items, opts...)
+	return nil
 }
 
-func (c *Cache) Load(r io.Reader) error {
+// 按保存时间与referenceNow的差值平移过期时间, 保持条目的剩余存活时间不变
+// 只支持GobCodec保存的数据
+func (c *Cache) LoadWithClockAdjust(r io.Reader, referenceNow time.Time) error {
+	if c.closed() {
+		return ErrClosed
+	}
+	if _, ok := c.codec().(GobCodec); !ok {
+		return fmt.Errorf("LoadWithClockAdjust requires GobCodec")
+	}
+	r, err := decompressed(r)
+	if err != nil {
+		return err
+	}
 	dec := gob.NewDecoder(r)
 	items := map[string]Item{}
-	err := dec.Decode(&items)
-	if err != nil {
+	if err := dec.Decode(&items); err != nil {
 		return err
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	var savedAt int64
+	if err := dec.Decode(&savedAt); err != nil {
+		return fmt.Errorf("Error reading save time from dump: %v", err)
+	}
+	delta := referenceNow.UnixNano() - savedAt
 	for k, v := range items {
-		item, ok := c.items[k]
-		if !ok || item.Expired() {
-			c.items[k] = v
+		if v.Expiration > 0 {
+			v.Expiration += delta
+		}
+		if v.Created > 0 {
+			v.Created += delta
 		}
+		items[k] = v
 	}
-	return err
+	c.load(items)
+	return nil
 }
 
-func (c *Cache) LoadFromFile(file string) error {
-	f, err := os.Open(file)
-	if err != nil {
-		return err
+type loadOptions struct {
+	dropExpired bool
+	rebase      bool
+	fresh       bool
+	freshTTL    time.Duration
+}
+
+type LoadOption func(o *loadOptions)
+
+// 不加载保存时已过期或加载时已过期的条目
+func WithDropExpired() LoadOption {
+	return func(o *loadOptions) { o.dropExpired = true }
+}
+
+// 有存活时间的条目从加载时起按写入时的存活时间重新计时, 与Touch相同
+func WithRebasedTTL() LoadOption {
+	return func(o *loadOptions) { o.rebase = true }
+}
+
+// 所有加载的条目以d为存活时间重新计时, d的含义与Set相同
+func WithFreshTTL(d time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.fresh = true
+		o.freshTTL = d
 	}
-	if err = c.Load(f); err != nil {
-		f.Close()
-		return err
+}
+
+// 按opts调整过期时间, 返回false表示丢弃; 是否过期按调整前的过期时间判断
+func (c *Cache) restore(item *Item, o *loadOptions, now time.Time) bool {
+	if o.dropExpired && c.expired(*item) {
+		return false
+	}
+	switch {
+	case o.fresh:
+		item.Expiration, item.TTL = c.expiration(o.freshTTL, now)
+	case o.rebase && item.TTL > 0:
+		item.Expiration = now.Add(item.TTL).UnixNano()
+	}
+	return true
+}
+
+func (c *Cache) load(items map[string]Item, opts ...LoadOption) {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	now := c.now()
+	for k, v := range items {
+		if c.restore(&v, &o, now) {
+			items[k] = v
+		} else {
+			delete(items, k)
+		}
+	}
+	for s, group := range c.groupItems(items) {
+		s.mu.Lock()
+		for k, v := range group {
+			item, ok := s.items[k]
+			if !ok || c.expired(item) {
+				s.store(k, v)
+			}
+		}
+		s.unlock()
 	}
-	return f.Close()
+	c.shrink()
+}
+
+func (c *Cache) LoadFromFile(file string, opts ...LoadOption) error {
+	return c.loadFile(file, func(r io.Reader) error { return c.Load(r, opts...) })
+}
+
+// 开启后每次Get等读取操作都把有存活时间的条目的过期时间延长为读取时间加TTL
+// 延长不写入append log, 重启后恢复为最后一次写入时的过期时间
+func (c *Cache) SetSlidingExpiration(on bool) {
+	c.configure(func(cfg *config) { cfg.sliding = on })
+}
+
+// 开启后读取到过期条目时立即删除并触发删除回调, 而不是等待下一次过期清理
+func (c *Cache) SetLazyExpiration(on bool) {
+	c.configure(func(cfg *config) { cfg.lazyExpire = on })
+}
+
+// 每个条目的存活时间在±fraction范围内随机浮动, 避免同时写入的大量条目同时过期; fraction取值[0, 1]
+func (c *Cache) SetTTLJitter(fraction float64) {
+	c.configure(func(cfg *config) { cfg.ttlJitter = clampJitter(fraction) })
+}
+
+func clampJitter(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// 开启后写入时的0和NoExpiration以外的负数存活时间被视为错误
+func (c *Cache) SetStrictTTL(on bool) {
+	c.configure(func(cfg *config) { cfg.strictTTL = on })
+}
+
+// 开启后Save不再recover gob的panic, 便于调试
+func (c *Cache) SetStrictSave(strict bool) {
+	c.configure(func(cfg *config) { cfg.strictSave = strict })
+}
+
+// 设置key的层级分隔符, 以DefaultExpiration写入的key会继承最近祖先key的过期时间
+func (c *Cache) SetKeyDelimiter(delim string) {
+	c.configure(func(cfg *config) { cfg.keyDelimiter = delim })
+}
+
+// 开启后Set/Add/Update传入0时不写入, 需要默认过期时间时不能再使用DefaultExpiration
+func (c *Cache) SetZeroDurationNoStore(on bool) {
+	c.configure(func(cfg *config) { cfg.zeroNoStore = on })
+}
+
+// 设置过期条目的归档回调, GC删除过期条目前调用
+func (c *Cache) OnExpireArchive(f func(k string, item Item) error) {
+	c.configure(func(cfg *config) { cfg.archive = f })
+}
+
+// 开启后归档回调返回错误的条目会保留到下一次GC重试
+func (c *Cache) SetArchiveRetainOnError(retain bool) {
+	c.configure(func(cfg *config) { cfg.archiveRetain = retain })
+}
+
+// 设置条目被删除时的回调: 过期清理, Delete和容量淘汰都会触发, 覆盖写和Flush不触发
+func (c *Cache) OnEvicted(f func(k string, v interface{})) {
+	c.configure(func(cfg *config) { cfg.onEvicted = f })
+}
+
+// 条目因任何原因被移除时调用, 包括被覆盖和Flush; 与OnEvicted互不影响
+func (c *Cache) OnRemoved(f func(k string, v interface{}, reason Reason)) {
+	c.configure(func(cfg *config) { cfg.onRemoved = f })
 }
 
 func (c *Cache) Count() int {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return len(c.items)
+	return int(atomic.LoadInt64(&c.count))
+}
+
+// 返回写入时间最早的有效条目及其存在时长
+func (c *Cache) OldestItem() (string, time.Duration, bool) {
+	return c.ageExtreme(func(a, b int64) bool { return a < b })
+}
+
+// 返回写入时间最晚的有效条目及其存在时长
+func (c *Cache) NewestItem() (string, time.Duration, bool) {
+	return c.ageExtreme(func(a, b int64) bool { return a > b })
+}
+
+func (c *Cache) ageExtreme(better func(a, b int64) bool) (string, time.Duration, bool) {
+	var (
+		key     string
+		created int64
+		found   bool
+	)
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for k, v := range s.items {
+			// 旧版本导出的数据没有写入时间
+			if v.Created == 0 || c.expired(v) {
+				continue
+			}
+			if !found || better(v.Created, created) {
+				key, created, found = k, v.Created, true
+			}
+		}
+		s.mu.RUnlock()
+	}
+	if !found {
+		return "", 0, false
+	}
+	return key, time.Duration(c.nowNano() - created), true
 }
 
 func (c *Cache) Flush() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.items = map[string]Item{}
+	if c.writable() != nil {
+		return
+	}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.flush()
+		s.unlock()
+	}
+	c.negative.flush()
+	c.notifySpace()
+}
+
+// 设置最大条目数, 小于等于0表示不限制; 分片时限制的是所有shard的总数
+func (c *Cache) SetMaxEntries(n int) {
+	c.configure(func(cfg *config) { cfg.maxEntries = n })
+	c.shrink()
+	c.notifySpace()
 }
 
+func (c *Cache) SetOverflowPolicy(p OverflowPolicy) {
+	c.configure(func(cfg *config) { cfg.overflow = p })
+	c.shrink()
+	c.notifySpace()
+}
+
+// 可重复调用, 未启动后台清理时直接返回; 停止后不能恢复, 需要临时停止时使用PauseGC
 func (c *Cache) StopGc() {
-	c.stopGc <- true
+	c.gcOnce.Do(func() { close(c.stopGc) })
+}
+
+// 暂停后台清理, 期间到期的清理直接跳过, 过期条目仍不会被读到
+func (c *Cache) PauseGC() {
+	atomic.StoreInt32(&c.gcPaused, 1)
 }
 
+// 恢复PauseGC暂停的后台清理, 从下一次到期时开始执行
+func (c *Cache) ResumeGC() {
+	atomic.StoreInt32(&c.gcPaused, 0)
+}
+
+// 使用默认的shard数量, 见WithShards
 func NewCache(defaultExpiration, gcInterval time.Duration) *Cache {
-	c := &Cache{
-		defaultExpiration: defaultExpiration,
-		gcInterval:        gcInterval,
-		items:             map[string]Item{},
-		stopGc:            make(chan bool),
-	}
-	go c.gcLoop()
-	return c
+	return New(WithDefaultTTL(defaultExpiration), WithGCInterval(gcInterval))
+}
+
+// 按key的哈希分为多个shard, 不同shard上的读写互不阻塞
+func NewShardedCache(defaultExpiration, gcInterval time.Duration, shards int) *Cache {
+	return New(WithDefaultTTL(defaultExpiration), WithGCInterval(gcInterval), WithShards(shards))
 }
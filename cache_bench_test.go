@@ -0,0 +1,209 @@
+package fcache
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	// 读取类基准预先写入的key数量
+	benchSize   = 100000
+	benchShards = 16
+)
+
+func benchKeys(n int) []string {
+	ks := make([]string, n)
+	for i := range ks {
+		ks[i] = "key:" + strconv.Itoa(i)
+	}
+	return ks
+}
+
+func benchFilled(n int, d time.Duration, opts ...Option) (*Cache, []string) {
+	c := New(append([]Option{WithShards(benchShards), WithGCInterval(0)}, opts...)...)
+	ks := benchKeys(n)
+	for i, k := range ks {
+		c.Set(k, i, d)
+	}
+	return c, ks
+}
+
+func BenchmarkSet(b *testing.B) {
+	c := New(WithShards(benchShards), WithGCInterval(0))
+	ks := benchKeys(benchSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Set(ks[i%len(ks)], i, NoExpiration)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	c, ks := benchFilled(benchSize, NoExpiration)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(ks[i%len(ks)])
+	}
+}
+
+func BenchmarkGetMiss(b *testing.B) {
+	c, ks := benchFilled(benchSize, NoExpiration)
+	for i := range ks {
+		ks[i] += ":miss"
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(ks[i%len(ks)])
+	}
+}
+
+// 90%读, 10%写
+func BenchmarkMixed90Read(b *testing.B) {
+	c, ks := benchFilled(benchSize, NoExpiration)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := ks[i%len(ks)]
+		if i%10 == 0 {
+			c.Set(k, i, NoExpiration)
+		} else {
+			c.Get(k)
+		}
+	}
+}
+
+func BenchmarkParallelGet(b *testing.B) {
+	c, ks := benchFilled(benchSize, NoExpiration)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(ks[i%len(ks)])
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelSet(b *testing.B) {
+	c := New(WithShards(benchShards), WithGCInterval(0))
+	ks := benchKeys(benchSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Set(ks[i%len(ks)], i, NoExpiration)
+			i++
+		}
+	})
+}
+
+func BenchmarkParallelMixed90Read(b *testing.B) {
+	c, ks := benchFilled(benchSize, NoExpiration)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := ks[i%len(ks)]
+			if i%10 == 0 {
+				c.Set(k, i, NoExpiration)
+			} else {
+				c.Get(k)
+			}
+			i++
+		}
+	})
+}
+
+// 一次过期清理的耗时, all时所有条目都已过期
+func BenchmarkDeleteExpired(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		n    int
+		all  bool
+	}{
+		{"none/100k", 100000, false},
+		{"none/1M", 1000000, false},
+		{"all/100k", 100000, true},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				clock := NewFakeClock(time.Unix(1000, 0))
+				c, _ := benchFilled(bm.n, time.Hour, WithClock(clock))
+				if bm.all {
+					clock.Advance(2 * time.Hour)
+				}
+				b.StartTimer()
+				c.DeleteExpired()
+			}
+		})
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	c, _ := benchFilled(benchSize, NoExpiration)
+	var buf bytes.Buffer
+	c.Save(&buf)
+	b.SetBytes(int64(buf.Len()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Save(io.Discard)
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	c, _ := benchFilled(benchSize, NoExpiration)
+	var buf bytes.Buffer
+	c.Save(&buf)
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New(WithShards(benchShards), WithGCInterval(0)).Load(bytes.NewReader(data))
+	}
+}
+
+func BenchmarkSaveSnapshot(b *testing.B) {
+	c, _ := benchFilled(benchSize, NoExpiration)
+	var buf bytes.Buffer
+	c.SaveSnapshot(&buf)
+	b.SetBytes(int64(buf.Len()))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.SaveSnapshot(io.Discard)
+	}
+}
+
+func BenchmarkBytesCacheSet(b *testing.B) {
+	c := NewBytesCache(benchShards, 64<<20/benchShards)
+	ks, v := benchKeys(benchSize), make([]byte, 128)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Set(ks[i%len(ks)], v, 0)
+	}
+}
+
+func BenchmarkBytesCacheGet(b *testing.B) {
+	c := NewBytesCache(benchShards, 64<<20/benchShards)
+	ks, v := benchKeys(benchSize), make([]byte, 128)
+	for _, k := range ks {
+		c.Set(k, v, 0)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Get(ks[i%len(ks)])
+	}
+}
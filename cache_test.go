@@ -0,0 +1,495 @@
+package fcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOldestNewestItem(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithShards(4), WithClock(clock), WithGCInterval(0))
+	if _, _, ok := c.OldestItem(); ok {
+		t.Fatal("OldestItem on empty cache")
+	}
+	// 最早写入的key过期后不再参与比较
+	c.Set("old", "old", 2*time.Second)
+	clock.Advance(time.Second)
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, k, NoExpiration)
+		clock.Advance(time.Second)
+	}
+
+	tests := []struct {
+		name    string
+		f       func() (string, time.Duration, bool)
+		wantKey string
+		wantAge time.Duration
+	}{
+		{"oldest", c.OldestItem, "a", 3 * time.Second},
+		{"newest", c.NewestItem, "c", time.Second},
+	}
+	for _, tt := range tests {
+		k, age, ok := tt.f()
+		if !ok || k != tt.wantKey || age != tt.wantAge {
+			t.Errorf("%s = %q, %v, %v; want %q, %v", tt.name, k, age, ok, tt.wantKey, tt.wantAge)
+		}
+	}
+}
+
+// 与gob自动注册的名字冲突, 注册时panic
+type strictSaveValue struct{ N int }
+
+func init() {
+	gob.RegisterName("fcache.strictSaveValue.renamed", strictSaveValue{})
+}
+
+func TestStrictSave(t *testing.T) {
+	tests := []struct {
+		name   string
+		strict bool
+	}{
+		{"recover", false},
+		{"strict", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(WithGCInterval(0))
+			c.SetStrictSave(tt.strict)
+			c.Set("k", strictSaveValue{1}, NoExpiration)
+			var panicked interface{}
+			var err error
+			func() {
+				defer func() { panicked = recover() }()
+				err = c.Save(&bytes.Buffer{})
+			}()
+			if !tt.strict {
+				if panicked != nil || err == nil {
+					t.Fatalf("got panic %v, err %v; want error", panicked, err)
+				}
+				return
+			}
+			if panicked == nil {
+				t.Fatalf("no panic, err %v", err)
+			}
+			if msg := fmt.Sprint(panicked); !strings.Contains(msg, "registering duplicate") {
+				t.Fatalf("panic %q does not carry the gob detail", msg)
+			}
+		})
+	}
+}
+
+func TestKeyDelimiterInheritsTTL(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithClock(clock), WithDefaultTTL(time.Minute), WithKeyDelimiter(":"), WithGCInterval(0))
+	c.Set("user", 1, time.Hour)
+	clock.Advance(10 * time.Minute)
+
+	tests := []struct {
+		key  string
+		d    time.Duration
+		want time.Duration
+	}{
+		{"user:1", DefaultExpiration, 50 * time.Minute},
+		{"user:1:name", DefaultExpiration, 50 * time.Minute},
+		{"user:2", 5 * time.Minute, 5 * time.Minute},
+		{"other:1", DefaultExpiration, time.Minute},
+	}
+	for _, tt := range tests {
+		c.Set(tt.key, 1, tt.d)
+		if got, ok := c.TTL(tt.key); !ok || got != tt.want {
+			t.Errorf("TTL(%s) = %v, %v; want %v", tt.key, got, ok, tt.want)
+		}
+	}
+
+	// 祖先永不过期时子key也永不过期
+	c.Set("forever", 1, NoExpiration)
+	c.Set("forever:child", 1, DefaultExpiration)
+	if got, ok := c.TTL("forever:child"); !ok || got != NoExpiration {
+		t.Errorf("TTL(forever:child) = %v, %v; want NoExpiration", got, ok)
+	}
+}
+
+func TestTypedGet(t *testing.T) {
+	c := New(WithGCInterval(0))
+	c.Set("int", int64(7), NoExpiration)
+	c.Set("float", 1.5, NoExpiration)
+	c.Set("str", "s", NoExpiration)
+	c.Set("plain", 7, NoExpiration)
+
+	tests := []struct {
+		name string
+		get  func() (interface{}, bool)
+		want interface{}
+		ok   bool
+	}{
+		{"int64", func() (interface{}, bool) { return c.GetInt64("int") }, int64(7), true},
+		{"int64 mismatch", func() (interface{}, bool) { return c.GetInt64("plain") }, int64(0), false},
+		{"int64 missing", func() (interface{}, bool) { return c.GetInt64("none") }, int64(0), false},
+		{"float64", func() (interface{}, bool) { return c.GetFloat64("float") }, 1.5, true},
+		{"float64 mismatch", func() (interface{}, bool) { return c.GetFloat64("int") }, 0.0, false},
+		{"string", func() (interface{}, bool) { return c.GetString("str") }, "s", true},
+		{"string mismatch", func() (interface{}, bool) { return c.GetString("float") }, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.get()
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMergeIncrement(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	dst := New(WithShards(4), WithClock(clock), WithGCInterval(0))
+	src := New(WithShards(2), WithClock(clock), WithGCInterval(0))
+	dst.Set("a", int64(1), time.Hour)
+	dst.Set("b", 2.5, NoExpiration)
+	dst.Set("mixed", "text", NoExpiration)
+	src.Set("a", int64(10), time.Minute)
+	src.Set("b", 0.5, NoExpiration)
+	src.Set("c", 3, NoExpiration)
+	src.Set("mixed", int64(1), NoExpiration)
+	src.Set("name", "x", NoExpiration)
+
+	skipped := dst.MergeIncrement(src)
+	sort.Strings(skipped)
+	if want := []string{"mixed", "name"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped = %v, want %v", skipped, want)
+	}
+	tests := []struct {
+		key  string
+		want interface{}
+		ttl  time.Duration
+	}{
+		{"a", int64(11), time.Hour},
+		{"b", 3.0, NoExpiration},
+		{"c", 3, NoExpiration},
+		{"mixed", "text", NoExpiration},
+	}
+	for _, tt := range tests {
+		if v, ok := dst.Get(tt.key); !ok || v != tt.want {
+			t.Errorf("Get(%s) = %v, %v; want %v", tt.key, v, ok, tt.want)
+		}
+		if ttl, _ := dst.TTL(tt.key); ttl != tt.ttl {
+			t.Errorf("TTL(%s) = %v, want %v", tt.key, ttl, tt.ttl)
+		}
+	}
+}
+
+// 新写入的key受容量上限约束, 版本号由目标cache分配
+func TestMergeIncrementLimits(t *testing.T) {
+	dst := New(WithShards(1), WithMaxEntries(2), WithOverflowPolicy(RejectOnFull), WithGCInterval(0))
+	src := New(WithGCInterval(0))
+	dst.Set("a", int64(1), NoExpiration)
+	for i := 0; i < 100; i++ {
+		src.Set("b", int64(i), NoExpiration)
+	}
+	src.Set("a", int64(1), NoExpiration)
+	src.Set("c", int64(1), NoExpiration)
+
+	skipped := dst.MergeIncrement(src)
+	if n := dst.Count(); n != 2 || len(skipped) != 1 {
+		t.Fatalf("Count = %d, skipped %v; want 2 entries and 1 skipped", n, skipped)
+	}
+	if v, _ := dst.Get("a"); v != int64(2) {
+		t.Errorf("a = %v, want 2", v)
+	}
+	_, srcVersion, _ := src.GetWithVersion("b")
+	if _, v, ok := dst.GetWithVersion("b"); ok && v == srcVersion {
+		t.Errorf("b kept the version %d of the other cache", v)
+	}
+}
+
+func TestZeroDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		noStore bool
+		stored  bool
+	}{
+		{"default expiration", false, true},
+		{"no store", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(WithDefaultTTL(time.Minute), WithGCInterval(0))
+			c.SetZeroDurationNoStore(tt.noStore)
+			c.Set("set", 1, 0)
+			c.Add("add", 1, 0)
+			c.Set("update", 1, NoExpiration)
+			c.Update("update", 2, 0)
+			for _, k := range []string{"set", "add"} {
+				if _, ok := c.Get(k); ok != tt.stored {
+					t.Errorf("%s stored = %v, want %v", k, ok, tt.stored)
+				}
+			}
+			if v, _ := c.Get("update"); (v == 2) != tt.stored {
+				t.Errorf("update = %v", v)
+			}
+			// SetWithDefaultTTL总是使用默认过期时间
+			c.SetWithDefaultTTL("explicit", 1)
+			if ttl, ok := c.TTL("explicit"); !ok || ttl <= 0 || ttl > time.Minute {
+				t.Errorf("TTL(explicit) = %v, %v", ttl, ok)
+			}
+		})
+	}
+}
+
+func TestExpireArchive(t *testing.T) {
+	tests := []struct {
+		name   string
+		retain bool
+		// 第一次清理后剩余的条目数, 包括保留的过期条目
+		count int
+	}{
+		{"drop on error", false, 1},
+		{"retain on error", true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			archived := map[string]interface{}{}
+			failed := false
+			c := New(WithClock(clock), WithGCInterval(0), WithExpireArchive(func(k string, item Item) error {
+				if k == "fail" && !failed {
+					failed = true
+					return errors.New("cold storage unavailable")
+				}
+				if item.Expiration > clock.Now().UnixNano() {
+					t.Errorf("%s archived before expiring", k)
+				}
+				archived[k] = item.Object
+				return nil
+			}, tt.retain))
+			c.Set("a", 1, time.Second)
+			c.Set("fail", 2, time.Second)
+			c.Set("live", 3, time.Hour)
+			clock.Advance(2 * time.Second)
+
+			c.RunGC()
+			if !reflect.DeepEqual(archived, map[string]interface{}{"a": 1}) {
+				t.Fatalf("archived = %v", archived)
+			}
+			if n := c.Count(); n != tt.count {
+				t.Fatalf("Count = %d, want %d", n, tt.count)
+			}
+			c.RunGC()
+			if _, ok := archived["fail"]; ok != tt.retain {
+				t.Errorf("fail archived on retry = %v, want %v", ok, tt.retain)
+			}
+			if n := c.Count(); n != 1 {
+				t.Errorf("Count = %d after retry, want 1", n)
+			}
+		})
+	}
+}
+
+func TestEnsureCounterConcurrent(t *testing.T) {
+	c := New(WithGCInterval(0))
+	var inits int64
+	c.OnRemoved(func(k string, v interface{}, reason Reason) {
+		if reason == Replaced {
+			atomic.AddInt64(&inits, 1)
+		}
+	})
+	var wg sync.WaitGroup
+	results := make([]int64, 64)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = c.EnsureCounter("n", time.Hour)
+		}(i)
+	}
+	wg.Wait()
+	for i, n := range results {
+		if n != 0 || errs[i] != nil {
+			t.Fatalf("caller %d saw %d, %v", i, n, errs[i])
+		}
+	}
+	// 只初始化一次, 没有被覆盖
+	info, ok := c.ItemInfo("n")
+	if n := atomic.LoadInt64(&inits); !ok || info.Version != 1 || n != 0 {
+		t.Fatalf("version %d, overwritten %d times", info.Version, n)
+	}
+
+	c.Increment("n", 5)
+	if n, err := c.EnsureCounter("n", time.Hour); n != 5 || err != nil {
+		t.Fatalf("EnsureCounter after Increment = %d, %v; want 5", n, err)
+	}
+	c.SetReadOnly(true)
+	if _, err := c.EnsureCounter("m", time.Hour); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("EnsureCounter read only: err %v, want ErrReadOnly", err)
+	}
+}
+
+func TestDeleteExpiredCollect(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithClock(clock), WithShards(4), WithGCInterval(0))
+	c.Set("a", 1, time.Second)
+	c.Set("b", 2, 2*time.Second)
+	c.Set("live", 3, time.Hour)
+	c.Set("forever", 4, NoExpiration)
+	clock.Advance(3 * time.Second)
+
+	removed := c.DeleteExpiredCollect()
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Key < removed[j].Key })
+	want := []struct {
+		key        string
+		value      interface{}
+		expiration time.Time
+	}{
+		{"a", 1, time.Unix(1001, 0)},
+		{"b", 2, time.Unix(1002, 0)},
+	}
+	if len(removed) != len(want) {
+		t.Fatalf("removed %d entries: %v", len(removed), removed)
+	}
+	for i, w := range want {
+		e := removed[i]
+		if e.Key != w.key || e.Item.Object != w.value || e.Item.Expiration != w.expiration.UnixNano() {
+			t.Errorf("entry %d = %s %v expires %v; want %s %v at %v", i, e.Key, e.Item.Object, time.Unix(0, e.Item.Expiration), w.key, w.value, w.expiration)
+		}
+		if e.Item.Expiration >= clock.Now().UnixNano() {
+			t.Errorf("%s expiration is not in the past", e.Key)
+		}
+	}
+	if n := c.Count(); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+	if removed := c.DeleteExpiredCollect(); len(removed) != 0 {
+		t.Errorf("second sweep removed %v", removed)
+	}
+}
+
+func TestLoadWithClockAdjust(t *testing.T) {
+	src := New(WithGCInterval(0))
+	src.Set("a", 1, time.Hour)
+	src.Set("forever", 2, NoExpiration)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.Bytes()
+
+	// 本机时钟比保存的机器快5小时
+	clock := NewFakeClock(time.Now().Add(5 * time.Hour))
+	tests := []struct {
+		name   string
+		load   func(c *Cache) error
+		ttl    time.Duration
+		loaded bool
+	}{
+		{"plain", func(c *Cache) error { return c.Load(bytes.NewReader(dump)) }, 0, false},
+		{"adjusted", func(c *Cache) error { return c.LoadWithClockAdjust(bytes.NewReader(dump), clock.Now()) }, time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(WithClock(clock), WithGCInterval(0))
+			if err := tt.load(c); err != nil {
+				t.Fatal(err)
+			}
+			ttl, ok := c.TTL("a")
+			if ok != tt.loaded || (ok && (ttl > tt.ttl || ttl < tt.ttl-time.Minute)) {
+				t.Errorf("TTL(a) = %v, %v; want about %v", ttl, ok, tt.ttl)
+			}
+			if ttl, ok := c.TTL("forever"); !ok || ttl != NoExpiration {
+				t.Errorf("TTL(forever) = %v, %v", ttl, ok)
+			}
+		})
+	}
+
+	c := New(WithGCInterval(0))
+	if err := c.LoadWithClockAdjust(bytes.NewReader(dump[:len(dump)-4]), time.Now()); err == nil {
+		t.Error("dump without save time accepted")
+	}
+}
+
+func TestRemoveExists(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	loads := 0
+	c := New(WithClock(clock), WithGCInterval(0), WithLoader(func(ctx context.Context, k string) (interface{}, time.Duration, error) {
+		loads++
+		return "loaded", time.Hour, nil
+	}))
+	c.Set("live", 1, NoExpiration)
+	c.Set("expired", 1, time.Second)
+	clock.Advance(2 * time.Second)
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"live", true},
+		{"expired", false},
+		{"missing", false},
+	}
+	for _, tt := range tests {
+		if got := c.Exists(tt.key); got != tt.want {
+			t.Errorf("Exists(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+		if got := c.Remove(tt.key); got != tt.want {
+			t.Errorf("Remove(%s) = %v, want %v", tt.key, got, tt.want)
+		}
+		if c.Remove(tt.key) || c.Exists(tt.key) {
+			t.Errorf("%s still present after Remove", tt.key)
+		}
+	}
+	if loads != 0 {
+		t.Fatalf("loader called %d times", loads)
+	}
+	if n := c.Count(); n != 0 {
+		t.Fatalf("Count = %d, want 0", n)
+	}
+}
+
+// strictTTL和zeroNoStore对各写入方法的效果一致
+func TestTTLOptions(t *testing.T) {
+	writes := []struct {
+		name string
+		set  func(c *Cache, d time.Duration) error
+	}{
+		{"SetCtx", func(c *Cache, d time.Duration) error { return c.SetCtx(context.Background(), "k", 1, d) }},
+		{"SetMulti", func(c *Cache, d time.Duration) error { return c.SetMulti(map[string]interface{}{"k": 1}, d) }},
+		{"SetWithTags", func(c *Cache, d time.Duration) error { return c.SetWithTags("k", 1, d, "t") }},
+		{"SetWithDeps", func(c *Cache, d time.Duration) error { return c.SetWithDeps("k", 1, d, "p") }},
+	}
+	tests := []struct {
+		name    string
+		opt     Option
+		d       time.Duration
+		wantErr error
+		stored  bool
+		ttl     time.Duration
+	}{
+		{"default zero", WithDefaultTTL(time.Minute), 0, nil, true, time.Minute},
+		{"strict zero", WithStrictTTL(), 0, ErrAmbiguousTTL, false, 0},
+		{"strict negative", WithStrictTTL(), -5, ErrAmbiguousTTL, false, 0},
+		{"strict forever", WithStrictTTL(), NoExpiration, nil, true, NoExpiration},
+		{"zero no store", WithZeroDurationNoStore(), 0, nil, false, 0},
+		{"zero no store positive", WithZeroDurationNoStore(), time.Hour, nil, true, time.Hour},
+	}
+	for _, w := range writes {
+		for _, tt := range tests {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			c := New(WithClock(clock), WithGCInterval(0), WithDefaultTTL(time.Minute), tt.opt)
+			if err := w.set(c, tt.d); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s %s: err %v, want %v", w.name, tt.name, err, tt.wantErr)
+			}
+			d, ok := c.TTL("k")
+			if ok != tt.stored || (ok && d != tt.ttl) {
+				t.Errorf("%s %s: TTL = %v, %v; want %v, %v", w.name, tt.name, d, ok, tt.ttl, tt.stored)
+			}
+		}
+	}
+}
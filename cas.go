@@ -0,0 +1,140 @@
+package fcache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// 不可比较的类型(slice, map等)直接返回false, 避免panic
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// 当前值等于old时替换为new, key不存在或已过期时返回false
+func (c *Cache) CompareAndSwap(k string, old, new interface{}, d time.Duration) bool {
+	if c.checkTTL(d) != nil || c.skipStore(d) || c.writable() != nil {
+		return false
+	}
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	cur, ok := s.get(k)
+	if !ok || !equal(cur, old) {
+		s.unlock()
+		return false
+	}
+	s.set(k, new, d)
+	s.unlock()
+	c.shrink()
+	return true
+}
+
+// 返回值及其版本号, 见Item.Version
+func (c *Cache) GetWithVersion(k string) (interface{}, uint64, bool) {
+	s := c.shard(k)
+	s.mu.Lock()
+	v, ok := s.get(k)
+	version := s.items[k].Version
+	s.unlock()
+	c.recordGet(ok)
+	if !ok {
+		return nil, 0, false
+	}
+	return v, version, true
+}
+
+// 版本号与version一致时写入, 用于乐观锁式的更新; 需要未写入的原因时使用SetIfVersionErr
+func (c *Cache) SetIfVersion(k string, v interface{}, version uint64, d time.Duration) bool {
+	return c.SetIfVersionErr(k, v, version, d) == nil
+}
+
+// 见SetIfVersion, 只有写入时返回nil: key不存在或已过期时返回ErrKeyNotFound, 版本号不一致时返回ErrVersionMismatch
+// 开启zeroNoStore时d为0返回ErrNotStored
+func (c *Cache) SetIfVersionErr(k string, v interface{}, version uint64, d time.Duration) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if err := c.writable(); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return fmt.Errorf("%w: %s", ErrNotStored, k)
+	}
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	item, ok := s.live(k)
+	if !ok {
+		s.unlock()
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, k)
+	}
+	if item.Version != version {
+		s.unlock()
+		return fmt.Errorf("%w: %s", ErrVersionMismatch, k)
+	}
+	s.set(k, v, d)
+	s.unlock()
+	c.shrink()
+	return nil
+}
+
+// 删除并返回当前值, 并发调用时只有一个调用者能拿到值; 缓存已关闭或只读而无法删除时返回false
+func (c *Cache) GetAndDelete(k string) (interface{}, bool) {
+	if c.writable() != nil {
+		return nil, false
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	v, ok := s.get(k)
+	if ok {
+		s.delete(k, Deleted)
+		atomic.AddUint64(&c.stats.deletes, 1)
+	}
+	s.unlock()
+	c.recordGet(ok)
+	return v, ok
+}
+
+// 写入v并返回写入前的值, key不存在或已过期时返回false; 写入被拒绝时不修改原值
+func (c *Cache) GetAndSet(k string, v interface{}, d time.Duration) (interface{}, bool) {
+	if c.checkTTL(d) != nil || c.skipStore(d) {
+		return c.Get(k)
+	}
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	if err := s.waitSpace(context.Background(), k); err != nil {
+		old, ok := s.get(k)
+		s.unlock()
+		return old, ok
+	}
+	old, ok := s.get(k)
+	s.set(k, v, d)
+	s.unlock()
+	c.shrink()
+	return old, ok
+}
+
+// 分配新的版本号
+func (c *Cache) nextVersion() uint64 {
+	return atomic.AddUint64(&c.version, 1)
+}
+
+// 加载的条目带有原来的版本号, 之后分配的版本号需大于它
+func (c *Cache) observeVersion(v uint64) {
+	for {
+		cur := atomic.LoadUint64(&c.version)
+		if v <= cur || atomic.CompareAndSwapUint64(&c.version, cur, v) {
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package fcache
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 删除后重新写入的key不会复用旧的版本号, 旧版本号的SetIfVersion失败
+func TestVersionNotReused(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Cache, clock *FakeClock)
+	}{
+		{"delete and set", func(c *Cache, clock *FakeClock) {
+			c.Delete("k")
+			c.Set("k", "v", NoExpiration)
+		}},
+		{"expire and set", func(c *Cache, clock *FakeClock) {
+			c.Expire("k", time.Second)
+			clock.Advance(2 * time.Second)
+			c.RunGC()
+			c.Set("k", "v", NoExpiration)
+		}},
+		{"flush and set", func(c *Cache, clock *FakeClock) {
+			c.Flush()
+			c.Set("k", "v", NoExpiration)
+		}},
+		{"other keys", func(c *Cache, clock *FakeClock) {
+			c.Set("other", 1, NoExpiration)
+			c.Delete("k")
+			c.Set("k", "v", NoExpiration)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			c := New(WithClock(clock), WithGCInterval(0))
+			c.Set("k", "v", NoExpiration)
+			_, stale, _ := c.GetWithVersion("k")
+			tt.change(c, clock)
+			_, cur, ok := c.GetWithVersion("k")
+			if !ok || cur == stale {
+				t.Fatalf("version %d reused", cur)
+			}
+			if c.SetIfVersion("k", "x", stale, NoExpiration) {
+				t.Fatal("SetIfVersion succeeded with a stale version")
+			}
+			if !c.SetIfVersion("k", "x", cur, NoExpiration) {
+				t.Fatal("SetIfVersion failed with the current version")
+			}
+		})
+	}
+}
+
+// 加载的条目保留版本号, 之后分配的版本号大于加载的版本号
+func TestVersionAfterLoad(t *testing.T) {
+	src := New(WithGCInterval(0))
+	for i := 0; i < 10; i++ {
+		src.Set("k", i, NoExpiration)
+	}
+	_, loaded, _ := src.GetWithVersion("k")
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	c := New(WithGCInterval(0))
+	if err := c.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, v, _ := c.GetWithVersion("k"); v != loaded {
+		t.Fatalf("loaded version %d, want %d", v, loaded)
+	}
+	c.Delete("k")
+	c.Set("k", 1, NoExpiration)
+	if _, v, _ := c.GetWithVersion("k"); v <= loaded {
+		t.Fatalf("version %d after reload not above %d", v, loaded)
+	}
+}
+
+func TestSetIfVersionErr(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		stale   bool
+		prepare func(c *Cache)
+		want    error
+	}{
+		{"current", "k", false, func(c *Cache) {}, nil},
+		{"stale", "k", true, func(c *Cache) {}, ErrVersionMismatch},
+		{"missing", "missing", false, func(c *Cache) {}, ErrKeyNotFound},
+		{"read only", "k", false, func(c *Cache) { c.SetReadOnly(true) }, ErrReadOnly},
+		{"closed", "k", false, func(c *Cache) { c.Close() }, ErrClosed},
+	}
+	for _, tt := range tests {
+		c := New(WithGCInterval(0), WithZeroDurationNoStore())
+		c.Set("k", "v", NoExpiration)
+		_, version, _ := c.GetWithVersion("k")
+		if tt.stale {
+			c.Set("k", "w", NoExpiration)
+		}
+		tt.prepare(c)
+		err := c.SetIfVersionErr(tt.key, "x", version, NoExpiration)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if ok := c.SetIfVersion(tt.key, "x", version, NoExpiration); ok {
+			t.Errorf("%s: SetIfVersion succeeded twice with the same version", tt.name)
+		}
+	}
+
+	// zeroNoStore下d为0时不写入, 两个版本的结果一致
+	c := New(WithGCInterval(0), WithZeroDurationNoStore())
+	c.Set("k", "v", NoExpiration)
+	_, version, _ := c.GetWithVersion("k")
+	if err := c.SetIfVersionErr("k", "x", version, 0); !errors.Is(err, ErrNotStored) {
+		t.Errorf("zero duration: got %v, want ErrNotStored", err)
+	}
+	if c.SetIfVersion("k", "x", version, 0) {
+		t.Error("zero duration: SetIfVersion reported a write")
+	}
+	if v, _ := c.Get("k"); v != "v" {
+		t.Errorf("value changed to %v", v)
+	}
+}
+
+// 只有一个调用者能拿到值, 无法删除时谁都拿不到
+func TestGetAndDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(c *Cache)
+		want    int
+	}{
+		{"writable", func(c *Cache) {}, 1},
+		{"read only", func(c *Cache) { c.SetReadOnly(true) }, 0},
+		{"closed", func(c *Cache) { c.Close() }, 0},
+	}
+	for _, tt := range tests {
+		c := New(WithGCInterval(0))
+		c.Set("token", "v", NoExpiration)
+		tt.prepare(c)
+		got := 0
+		for i := 0; i < 3; i++ {
+			if v, ok := c.GetAndDelete("token"); ok {
+				if v != "v" {
+					t.Errorf("%s: GetAndDelete = %v", tt.name, v)
+				}
+				got++
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: value returned %d times, want %d", tt.name, got, tt.want)
+		}
+	}
+}
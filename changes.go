@@ -0,0 +1,150 @@
+package fcache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// 删除记录, 用于增量导出
+type tombstone struct {
+	seq uint64
+	at  int64
+}
+
+// 记录显式删除和清空, 供Changes导出; 删除记录保留retention后在过期清理时丢弃
+// 淘汰和过期不记录, 接收方按条目自身的过期时间过期
+func WithChangeLog(retention time.Duration) Option {
+	return func(o *options) { o.changeLog = retention }
+}
+
+// 自上次调用Changes以来的变化
+type Delta struct {
+	// 下一次调用Changes时传入的序号
+	Seq uint64
+	// 写入或更新的未过期条目
+	Items map[string]Item
+	// 被删除的key
+	Deleted []string
+}
+
+// 当前的写入序号, 每次写入和删除递增
+func (c *Cache) Seq() uint64 {
+	return atomic.LoadUint64(&c.seq)
+}
+
+// 返回序号since之后写入和删除的key, since为0时返回所有未过期的条目
+// 逐个shard在读锁下复制, 复制期间的写入可能出现在本次和下一次的结果中, 应用多次结果不变
+// since早于保留的删除记录时返回ErrChangesExpired, 需要重新全量同步
+func (c *Cache) Changes(since uint64) (*Delta, error) {
+	if c.conf().changeLog <= 0 {
+		return nil, fmt.Errorf("Change log not enabled")
+	}
+	if since > 0 && since < atomic.LoadUint64(&c.pruned) {
+		return nil, fmt.Errorf("%w: %d", ErrChangesExpired, since)
+	}
+	d := &Delta{Seq: c.Seq(), Items: map[string]Item{}}
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for k, v := range s.items {
+			if (since > 0 && v.seq <= since) || c.expired(v) || s.pending(k) {
+				continue
+			}
+			item := v.unpacked()
+			item.Object = c.copyValue(item.Object)
+			d.Items[k] = item
+		}
+		if since > 0 {
+			for k, t := range s.tombstones {
+				if t.seq > since {
+					d.Deleted = append(d.Deleted, k)
+				}
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return d, nil
+}
+
+// 应用另一个实例Changes的结果: 覆盖写入其中的条目并保留原过期时间, 删除其中被删除的key
+// 已过期的条目不写入, 超出容量时按淘汰策略淘汰
+func (c *Cache) ApplyChanges(d *Delta) error {
+	if err := c.writable(); err != nil {
+		return err
+	}
+	for s, group := range c.groupKeys(d.Deleted) {
+		s.mu.Lock()
+		for _, k := range group {
+			if s.deleteKey(k) {
+				atomic.AddUint64(&c.stats.deletes, 1)
+			}
+		}
+		s.unlock()
+	}
+	items := make(map[string]Item, len(d.Items))
+	for k, v := range d.Items {
+		if !c.expired(v) {
+			items[k] = v
+		}
+	}
+	for s, group := range c.groupItems(items) {
+		s.mu.Lock()
+		for k, v := range group {
+			s.store(k, v)
+		}
+		s.unlock()
+	}
+	c.shrink()
+	return nil
+}
+
+// 以gob编码写入Changes的结果, 返回下一次传入的序号
+func (c *Cache) SaveChanges(w io.Writer, since uint64) (uint64, error) {
+	d, err := c.Changes(since)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range d.Items {
+		register(v.Object)
+	}
+	return d.Seq, gob.NewEncoder(w).Encode(d)
+}
+
+// 读取SaveChanges写入的结果并应用, 返回其中的序号
+func (c *Cache) LoadChanges(r io.Reader) (uint64, error) {
+	var d Delta
+	if err := gob.NewDecoder(r).Decode(&d); err != nil {
+		return 0, err
+	}
+	return d.Seq, c.ApplyChanges(&d)
+}
+
+// 调用时需持有写锁
+func (s *shard) tombstone(k string) {
+	if s.c.conf().changeLog <= 0 {
+		return
+	}
+	if s.tombstones == nil {
+		s.tombstones = map[string]tombstone{}
+	}
+	s.tombstones[k] = tombstone{seq: atomic.AddUint64(&s.c.seq, 1), at: s.c.nowNano()}
+}
+
+// 丢弃超过保留时间的删除记录, 调用时需持有写锁
+func (s *shard) pruneTombstones(now int64) {
+	retention := s.c.conf().changeLog
+	for k, t := range s.tombstones {
+		if now-t.at <= int64(retention) {
+			continue
+		}
+		delete(s.tombstones, k)
+		for {
+			p := atomic.LoadUint64(&s.c.pruned)
+			if t.seq <= p || atomic.CompareAndSwapUint64(&s.c.pruned, p, t.seq) {
+				break
+			}
+		}
+	}
+}
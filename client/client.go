@@ -0,0 +1,309 @@
+// 按一致性哈希把key分布到多个fcache服务实例上, 实例可以是httpserver或grpcserver
+// 实例不可用时读写转移到哈希环上的下一个实例, 健康检查通过后恢复; 其他实例上的key不会移动
+// 实例恢复后可能返回其不可用期间被覆盖或删除的旧值, 直到过期
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// 所有负责该key的实例都不可用
+	ErrNoNodes = errors.New("client: no available nodes")
+	// Node的实现以%w包装这个错误表示实例无法访问, 其他错误不会使实例被标记为不可用
+	ErrUnavailable = errors.New("client: node unavailable")
+)
+
+// 一个fcache服务实例
+type Node interface {
+	// 用于在哈希环上定位实例, 各实例需唯一且在重启后保持不变, 通常为地址
+	Addr() string
+	// key不存在时返回false和nil错误
+	Get(ctx context.Context, k string) ([]byte, bool, error)
+	Set(ctx context.Context, k string, v []byte, d time.Duration) error
+	Delete(ctx context.Context, k string) error
+	Ping(ctx context.Context) error
+}
+
+type options struct {
+	replicas  int
+	vnodes    int
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+	onError   func(addr string, err error)
+}
+
+type Option func(*options)
+
+// 每个key写入的实例数, 默认为1; 读取时依次尝试这些实例
+func WithReplicas(n int) Option {
+	return func(o *options) { o.replicas = n }
+}
+
+// 每个实例在哈希环上的虚拟节点数, 默认为160
+func WithVirtualNodes(n int) Option {
+	return func(o *options) { o.vnodes = n }
+}
+
+// 每隔interval对所有实例调用Ping, 超过timeout视为失败; interval小于等于0时不做健康检查, 不可用的实例不会恢复
+// 默认每5秒检查一次, 超时1秒
+func WithHealthCheck(interval, timeout time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+		o.timeout = timeout
+	}
+}
+
+// 连续失败n次后标记为不可用, 默认为1
+func WithFailureThreshold(n int) Option {
+	return func(o *options) { o.threshold = n }
+}
+
+// 访问实例失败时调用, 包括健康检查
+func WithOnError(f func(addr string, err error)) Option {
+	return func(o *options) { o.onError = f }
+}
+
+type member struct {
+	node  Node
+	fails int32
+	down  int32
+}
+
+type point struct {
+	hash uint64
+	m    *member
+}
+
+type Pool struct {
+	opts    options
+	members []*member
+	ring    []point
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func New(nodes []Node, opts ...Option) (*Pool, error) {
+	o := options{
+		replicas:  1,
+		vnodes:    160,
+		interval:  5 * time.Second,
+		timeout:   time.Second,
+		threshold: 1,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("No nodes")
+	}
+	if o.replicas < 1 || o.vnodes < 1 || o.threshold < 1 {
+		return nil, fmt.Errorf("Replicas, virtual nodes and failure threshold must be positive")
+	}
+	if o.replicas > len(nodes) {
+		o.replicas = len(nodes)
+	}
+	p := &Pool{opts: o, stop: make(chan struct{}), done: make(chan struct{})}
+	seen := map[string]bool{}
+	for _, n := range nodes {
+		addr := n.Addr()
+		if seen[addr] {
+			return nil, fmt.Errorf("Duplicate node %s", addr)
+		}
+		seen[addr] = true
+		m := &member{node: n}
+		p.members = append(p.members, m)
+		for i := 0; i < o.vnodes; i++ {
+			p.ring = append(p.ring, point{hash: hash(fmt.Sprintf("%s#%d", addr, i)), m: m})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	if o.interval > 0 {
+		go p.healthLoop()
+	} else {
+		close(p.done)
+	}
+	return p, nil
+}
+
+// fnv-1a
+func hash(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	// fnv的低位分布较差, 再混合一次使相近的虚拟节点名在环上分散
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return h
+}
+
+// 按哈希环顺时针方向返回负责k的前n个可用实例, 跳过不可用的实例
+func (p *Pool) owners(k string, n int) []*member {
+	h := hash(k)
+	i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+	var ms []*member
+	seen := make(map[*member]bool, n)
+	for j := 0; j < len(p.ring) && len(ms) < n && len(seen) < len(p.members); j++ {
+		m := p.ring[(i+j)%len(p.ring)].m
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		if atomic.LoadInt32(&m.down) == 0 {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// 返回k当前所在的实例地址, 没有可用实例时返回空字符串
+func (p *Pool) NodeFor(k string) string {
+	if ms := p.owners(k, 1); len(ms) > 0 {
+		return ms[0].node.Addr()
+	}
+	return ""
+}
+
+// 依次读取负责k的实例, 返回第一个可访问的实例的结果
+func (p *Pool) Get(ctx context.Context, k string) ([]byte, bool, error) {
+	err := ErrNoNodes
+	for _, m := range p.owners(k, p.opts.replicas) {
+		var v []byte
+		var ok bool
+		if v, ok, err = m.node.Get(ctx, k); err == nil {
+			p.succeed(m)
+			return v, ok, nil
+		}
+		if !p.fail(ctx, m, err) {
+			return nil, false, err
+		}
+	}
+	return nil, false, err
+}
+
+// 写入负责k的所有实例, 至少一个成功时返回nil
+func (p *Pool) Set(ctx context.Context, k string, v []byte, d time.Duration) error {
+	return p.each(ctx, k, func(n Node) error { return n.Set(ctx, k, v, d) })
+}
+
+// 从负责k的所有实例删除, 至少一个成功时返回nil
+func (p *Pool) Delete(ctx context.Context, k string) error {
+	return p.each(ctx, k, func(n Node) error { return n.Delete(ctx, k) })
+}
+
+func (p *Pool) each(ctx context.Context, k string, f func(n Node) error) error {
+	ms := p.owners(k, p.opts.replicas)
+	if len(ms) == 0 {
+		return ErrNoNodes
+	}
+	errs := make([]error, len(ms))
+	var wg sync.WaitGroup
+	for i, m := range ms {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			errs[i] = f(m.node)
+		}(i, m)
+	}
+	wg.Wait()
+	var err error
+	ok := false
+	for i, m := range ms {
+		if errs[i] == nil {
+			p.succeed(m)
+			ok = true
+			continue
+		}
+		p.fail(ctx, m, errs[i])
+		err = errs[i]
+	}
+	if ok {
+		return nil
+	}
+	return err
+}
+
+func (p *Pool) succeed(m *member) {
+	atomic.StoreInt32(&m.fails, 0)
+}
+
+// 返回true表示err是实例无法访问, 应转移到下一个实例
+func (p *Pool) fail(ctx context.Context, m *member, err error) bool {
+	if ctx.Err() != nil || !errors.Is(err, ErrUnavailable) {
+		return false
+	}
+	p.failed(m, err)
+	return true
+}
+
+func (p *Pool) failed(m *member, err error) {
+	if f := p.opts.onError; f != nil {
+		f(m.node.Addr(), err)
+	}
+	if atomic.AddInt32(&m.fails, 1) >= int32(p.opts.threshold) {
+		atomic.StoreInt32(&m.down, 1)
+	}
+}
+
+func (p *Pool) healthLoop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.check()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *Pool) check() {
+	var wg sync.WaitGroup
+	for _, m := range p.members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), p.opts.timeout)
+			defer cancel()
+			if err := m.node.Ping(ctx); err != nil {
+				p.failed(m, err)
+				return
+			}
+			atomic.StoreInt32(&m.fails, 0)
+			atomic.StoreInt32(&m.down, 0)
+		}(m)
+	}
+	wg.Wait()
+}
+
+// 返回各实例地址及是否可用
+func (p *Pool) Nodes() map[string]bool {
+	st := make(map[string]bool, len(p.members))
+	for _, m := range p.members {
+		st[m.node.Addr()] = atomic.LoadInt32(&m.down) == 0
+	}
+	return st
+}
+
+// 停止健康检查, 不关闭实例的连接
+func (p *Pool) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+	return nil
+}
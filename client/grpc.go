@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fredalxin/fcache/grpcserver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcserver提供的gRPC服务
+type GRPCNode struct {
+	addr   string
+	client *grpcserver.Client
+}
+
+// cc由调用方创建和关闭, addr用于在哈希环上定位实例
+func NewGRPCNode(addr string, cc grpc.ClientConnInterface) *GRPCNode {
+	return &GRPCNode{addr: addr, client: grpcserver.NewClient(cc)}
+}
+
+func (n *GRPCNode) Addr() string {
+	return n.addr
+}
+
+func (n *GRPCNode) Get(ctx context.Context, k string) ([]byte, bool, error) {
+	v, ok, err := n.client.Get(ctx, k)
+	return v, ok, wrap(err)
+}
+
+// d小于等于0表示永不过期, 见grpcserver.Client.Set
+func (n *GRPCNode) Set(ctx context.Context, k string, v []byte, d time.Duration) error {
+	return wrap(n.client.Set(ctx, k, v, d))
+}
+
+func (n *GRPCNode) Delete(ctx context.Context, k string) error {
+	return wrap(n.client.Delete(ctx, k))
+}
+
+// 服务端拒绝空key, 返回InvalidArgument说明服务可用
+func (n *GRPCNode) Ping(ctx context.Context) error {
+	_, _, err := n.client.Get(ctx, "")
+	if err == nil || status.Code(err) == codes.InvalidArgument {
+		return nil
+	}
+	return wrap(err)
+}
+
+// Unavailable表示连接失败或服务端缓存已关闭
+func wrap(err error) error {
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpserver提供的HTTP接口
+type HTTPNode struct {
+	base   string
+	client *http.Client
+}
+
+// base为服务地址, 如http://10.0.0.1:8080; hc为nil时使用http.DefaultClient
+func NewHTTPNode(base string, hc *http.Client) *HTTPNode {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &HTTPNode{base: strings.TrimSuffix(base, "/"), client: hc}
+}
+
+func (n *HTTPNode) Addr() string {
+	return n.base
+}
+
+// 连接失败和5xx以外的响应码不视为实例不可用
+func (n *HTTPNode) do(ctx context.Context, method, path string, body []byte, h http.Header) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, n.base+path, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return resp, nil
+}
+
+func (n *HTTPNode) Get(ctx context.Context, k string) ([]byte, bool, error) {
+	resp, err := n.do(ctx, http.MethodGet, "/cache/"+url.PathEscape(k), nil, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return b, true, nil
+	case http.StatusNotFound:
+		return nil, false, nil
+	}
+	return nil, false, statusError(resp)
+}
+
+// d为fcache.DefaultExpiration时使用服务端的默认过期时间, 小于0表示永不过期
+func (n *HTTPNode) Set(ctx context.Context, k string, v []byte, d time.Duration) error {
+	h := http.Header{}
+	if d < 0 {
+		h.Set("X-Cache-TTL", "-1")
+	} else if d > 0 {
+		h.Set("X-Cache-TTL", d.String())
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	resp, err := n.do(ctx, http.MethodPut, "/cache/"+url.PathEscape(k), v, h)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return statusError(resp)
+	}
+	return nil
+}
+
+func (n *HTTPNode) Delete(ctx context.Context, k string) error {
+	resp, err := n.do(ctx, http.MethodDelete, "/cache/"+url.PathEscape(k), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return statusError(resp)
+	}
+	return nil
+}
+
+func (n *HTTPNode) Ping(ctx context.Context) error {
+	resp, err := n.do(ctx, http.MethodGet, "/stats", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+	return nil
+}
+
+// 503以外的5xx视为实例不可用, 503表示缓存已满或已关闭
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode/100 == 5 && resp.StatusCode != http.StatusServiceUnavailable {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package fcache
+
+import (
+	"sync"
+	"time"
+)
+
+// 过期判断和过期清理使用的时钟, 测试中可以用FakeClock代替真实时间
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTicker) Stop() {
+	t.t.Stop()
+}
+
+// 只在调用Advance或Set时前进的时钟
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{f: f, d: d, next: f.now.Add(d), c: make(chan time.Time, 1)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	now := f.now.Add(d)
+	f.mu.Unlock()
+	f.Set(now)
+}
+
+// 跨过的每个周期触发一次ticker, 与time.Ticker相同, 接收方来不及处理时多余的触发被丢弃
+func (f *FakeClock) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+	for _, t := range f.tickers {
+		for !t.next.After(now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.d)
+		}
+	}
+}
+
+type fakeTicker struct {
+	f    *FakeClock
+	d    time.Duration
+	next time.Time
+	c    chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	for i, x := range t.f.tickers {
+		if x == t {
+			t.f.tickers = append(t.f.tickers[:i], t.f.tickers[i+1:]...)
+			break
+		}
+	}
+}
+
+// cache的时钟的当前时间, 未设置WithClock时为系统时间
+func (c *Cache) Now() time.Time {
+	return c.now()
+}
+
+func (c *Cache) now() time.Time {
+	return c.clock.Now()
+}
+
+func (c *Cache) nowNano() int64 {
+	return c.clock.Now().UnixNano()
+}
+
+// 按cache的时钟判断是否过期
+func (c *Cache) expired(item Item) bool {
+	return item.ExpiredAt(c.now())
+}
+
+// 到过期时间e剩余的时间
+func (c *Cache) remaining(e int64) time.Duration {
+	return time.Duration(e - c.nowNano())
+}
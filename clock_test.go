@@ -0,0 +1,40 @@
+package fcache
+
+import (
+	"testing"
+	"time"
+)
+
+// 取出的条目用ExpiredAt(c.Now())按cache的时钟判断过期, 与Get一致
+func TestItemExpiredWithFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now().Add(-time.Hour))
+	c := New(WithClock(clock), WithGCInterval(0))
+	c.Set("a", 1, time.Minute)
+	c.Set("forever", 1, NoExpiration)
+	items := c.Items()
+	// 系统时间已经超过过期时间
+	if !items["a"].Expired() {
+		t.Fatal("Expired() should use the wall clock")
+	}
+
+	tests := []struct {
+		advance time.Duration
+		want    bool
+	}{
+		{0, false},
+		{30 * time.Second, false},
+		{time.Minute, true},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		if got := items["a"].ExpiredAt(c.Now()); got != tt.want {
+			t.Errorf("after %v: ExpiredAt = %v, want %v", tt.advance, got, tt.want)
+		}
+		if _, ok := c.Get("a"); ok == tt.want {
+			t.Errorf("after %v: Get ok = %v, want %v", tt.advance, ok, !tt.want)
+		}
+		if items["forever"].ExpiredAt(c.Now()) {
+			t.Error("item without expiration expired")
+		}
+	}
+}
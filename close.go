@@ -0,0 +1,65 @@
+package fcache
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+func (c *Cache) closed() bool {
+	return atomic.LoadInt32(&c.isClosed) == 1
+}
+
+// 停止后台清理和自动保存, 写入最后一次快照并关闭追加日志
+// 关闭后不再触发删除回调, 写入和删除返回ErrClosed或不生效, 读取仍可进行; 多次调用只生效一次
+func (c *Cache) Close() error {
+	return c.CloseWithContext(context.Background())
+}
+
+// 与Close相同, ctx结束时不再等待收尾完成并返回ctx的错误, 收尾仍在后台继续
+func (c *Cache) CloseWithContext(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		atomic.StoreInt32(&c.isClosed, 1)
+		c.closeDone = make(chan struct{})
+		go func() {
+			defer close(c.closeDone)
+			c.closeErr = c.shutdown()
+		}()
+	})
+	select {
+	case <-c.closeDone:
+		return c.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Cache) shutdown() error {
+	c.StopGc()
+	c.watch.closeAll()
+	// 唤醒BlockOnFull下等待的写入, 使其返回ErrClosed
+	c.notifySpace()
+	var err error
+	if a := c.autoSave; a != nil {
+		close(a.stop)
+		<-a.done
+		err = c.saveSnapshotAtomic(a)
+	}
+	if a := c.aof.Load(); a != nil {
+		if aerr := a.close(); err == nil {
+			err = aerr
+		}
+	}
+	if m := c.mapped; m != nil {
+		if merr := m.close(); err == nil {
+			err = merr
+		}
+	}
+	if d := c.disk; d != nil {
+		if derr := d.close(); err == nil {
+			err = derr
+		}
+	} else if err == nil {
+		err = c.diskErr
+	}
+	return err
+}
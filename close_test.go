@@ -0,0 +1,61 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// 关闭后所有修改操作都不生效
+func TestMutatorsAfterClose(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(c *Cache) error
+	}{
+		{"Set", func(c *Cache) error { c.Set("a", 2, NoExpiration); return nil }},
+		{"SetCtx", func(c *Cache) error { return c.SetCtx(context.Background(), "a", 2, NoExpiration) }},
+		{"Add", func(c *Cache) error { return c.Add("new", 2, NoExpiration) }},
+		{"Update", func(c *Cache) error { return c.Update("a", 2, NoExpiration) }},
+		{"Delete", func(c *Cache) error { c.Delete("a"); return nil }},
+		{"DeleteMulti", func(c *Cache) error { c.DeleteMulti([]string{"a"}); return nil }},
+		{"Flush", func(c *Cache) error { c.Flush(); return nil }},
+		{"FlushNamespace", func(c *Cache) error { c.FlushNamespace(""); return nil }},
+		{"DeleteByTag", func(c *Cache) error { c.DeleteByTag("t"); return nil }},
+		{"DeleteRegexp", func(c *Cache) error { c.DeleteRegexp(regexp.MustCompile(".")); return nil }},
+		{"DeleteItemFunc", func(c *Cache) error {
+			c.DeleteItemFunc(func(string, Item) bool { return true })
+			return nil
+		}},
+		{"GetAndDelete", func(c *Cache) error { c.GetAndDelete("a"); return nil }},
+		{"Increment", func(c *Cache) error { _, err := c.Increment("a", 1); return err }},
+		{"Expire", func(c *Cache) error { c.Expire("a", time.Nanosecond); return nil }},
+		{"Pin", func(c *Cache) error { c.Pin("a"); return nil }},
+		{"MergeIncrement", func(c *Cache) error {
+			other := New(WithGCInterval(0))
+			other.Set("a", 5, NoExpiration)
+			c.MergeIncrement(other)
+			return nil
+		}},
+		{"SetIfVersion", func(c *Cache) error { c.SetIfVersion("a", 2, 1, NoExpiration); return nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(WithGCInterval(0))
+			c.SetWithTags("a", 1, NoExpiration, "t")
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.f(c); err != nil && !errors.Is(err, ErrClosed) {
+				t.Fatalf("err %v, want ErrClosed", err)
+			}
+			if v, ok := c.Get("a"); !ok || v != 1 || c.Count() != 1 || c.IsPinned("a") {
+				t.Fatalf("cache changed after Close: %v, %v, count %d", v, ok, c.Count())
+			}
+			if ttl, _ := c.TTL("a"); ttl != NoExpiration {
+				t.Fatalf("TTL changed after Close: %v", ttl)
+			}
+		})
+	}
+}
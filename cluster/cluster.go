@@ -0,0 +1,166 @@
+// 在多个进程的本地Cache之间广播删除和清空操作, 写入后其他实例的旧值会失效
+// 传输方式可替换, 内置了UDP组播的实现; Redis pub/sub, NATS等可自行实现Broadcaster和Listener
+package cluster
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/fredalxin/fcache"
+)
+
+type Op uint8
+
+const (
+	OpDelete Op = iota + 1
+	OpFlush
+	OpDeletePattern
+	OpDeleteTag
+)
+
+// Origin为发送方的Node id, 用于忽略自己发出的消息
+type Message struct {
+	Op     Op     `json:"op"`
+	Key    string `json:"key,omitempty"`
+	Origin string `json:"origin"`
+}
+
+// 把编码后的消息发送给其他实例
+type Broadcaster interface {
+	Broadcast(msg []byte) error
+}
+
+// Listen阻塞并对收到的每条消息调用handle, 直到Close
+type Listener interface {
+	Listen(handle func(msg []byte)) error
+	Close() error
+}
+
+type Node struct {
+	cache *fcache.Cache
+	id    string
+	b     Broadcaster
+	l     Listener
+	// 处理消息失败和广播失败时调用, 可以为nil
+	OnError func(err error)
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// l为nil时只广播不接收
+func New(c *fcache.Cache, b Broadcaster, l Listener) *Node {
+	n := &Node{
+		cache: c,
+		id:    newID(),
+		b:     b,
+		l:     l,
+		done:  make(chan struct{}),
+	}
+	if l == nil {
+		close(n.done)
+		return n
+	}
+	go func() {
+		defer close(n.done)
+		if err := l.Listen(n.handle); err != nil {
+			n.error(err)
+		}
+	}()
+	return n
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+func (n *Node) Cache() *fcache.Cache {
+	return n.cache
+}
+
+// 写入本地后通知其他实例删除该key, 下次读取时由各自重新加载
+func (n *Node) Set(k string, v interface{}, d time.Duration) error {
+	n.cache.Set(k, v, d)
+	return n.broadcast(OpDelete, k)
+}
+
+func (n *Node) Delete(k string) error {
+	n.cache.Delete(k)
+	return n.broadcast(OpDelete, k)
+}
+
+func (n *Node) DeletePattern(pattern string) error {
+	if _, err := n.cache.DeletePattern(pattern); err != nil {
+		return err
+	}
+	return n.broadcast(OpDeletePattern, pattern)
+}
+
+func (n *Node) DeleteByTag(tag string) error {
+	n.cache.DeleteByTag(tag)
+	return n.broadcast(OpDeleteTag, tag)
+}
+
+func (n *Node) Flush() error {
+	n.cache.Flush()
+	return n.broadcast(OpFlush, "")
+}
+
+func (n *Node) broadcast(op Op, k string) error {
+	msg, err := json.Marshal(&Message{Op: op, Key: k, Origin: n.id})
+	if err != nil {
+		return err
+	}
+	if err = n.b.Broadcast(msg); err != nil {
+		n.error(err)
+	}
+	return err
+}
+
+func (n *Node) handle(b []byte) {
+	var msg Message
+	if err := json.Unmarshal(b, &msg); err != nil {
+		n.error(err)
+		return
+	}
+	if msg.Origin == n.id {
+		return
+	}
+	switch msg.Op {
+	case OpDelete:
+		n.cache.Delete(msg.Key)
+	case OpFlush:
+		n.cache.Flush()
+	case OpDeletePattern:
+		if _, err := n.cache.DeletePattern(msg.Key); err != nil {
+			n.error(err)
+		}
+	case OpDeleteTag:
+		n.cache.DeleteByTag(msg.Key)
+	}
+}
+
+func (n *Node) error(err error) {
+	if n.OnError != nil {
+		n.OnError(err)
+	}
+}
+
+// 关闭Listener并等待接收结束, 不关闭Cache和Broadcaster
+func (n *Node) Close() error {
+	var err error
+	n.closeOnce.Do(func() {
+		if n.l != nil {
+			err = n.l.Close()
+		}
+		<-n.done
+	})
+	return err
+}
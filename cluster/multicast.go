@@ -0,0 +1,67 @@
+package cluster
+
+import (
+	"errors"
+	"net"
+)
+
+// 单条消息的大小上限
+const maxDatagram = 64 * 1024
+
+// 基于UDP组播的传输, 同一组播地址上的所有实例都会收到消息
+// UDP不保证送达, 丢失的消息只能依靠各实例的过期时间兜底
+type Multicast struct {
+	group *net.UDPAddr
+	send  *net.UDPConn
+	recv  *net.UDPConn
+}
+
+// addr为组播地址, 如"239.0.0.1:9999"; ifi为nil时使用系统默认的网卡
+func NewMulticast(addr string, ifi *net.Interface) (*Multicast, error) {
+	group, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := net.ListenMulticastUDP("udp", ifi, group)
+	if err != nil {
+		return nil, err
+	}
+	send, err := net.DialUDP("udp", nil, group)
+	if err != nil {
+		recv.Close()
+		return nil, err
+	}
+	return &Multicast{group: group, send: send, recv: recv}, nil
+}
+
+func (m *Multicast) Broadcast(msg []byte) error {
+	if len(msg) > maxDatagram {
+		return errors.New("cluster: message too large")
+	}
+	_, err := m.send.Write(msg)
+	return err
+}
+
+func (m *Multicast) Listen(handle func(msg []byte)) error {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, _, err := m.recv.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		msg := make([]byte, n)
+		copy(msg, buf[:n])
+		handle(msg)
+	}
+}
+
+func (m *Multicast) Close() error {
+	err := m.recv.Close()
+	if serr := m.send.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
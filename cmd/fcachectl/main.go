@@ -0,0 +1,245 @@
+// 管理工具, 通过httpserver提供的HTTP接口操作运行中的缓存, 或直接读取保存的文件
+//
+//	fcachectl [-addr URL] keys [PATTERN] | get KEY | set KEY VALUE [TTL] | del KEY | stats
+//	fcachectl -file PATH [-format F] [-key HEX] keys [PATTERN] | get KEY | stats | dump
+//	fcachectl [-format F] [-key HEX] convert IN OUT gob|json|snapshot
+//
+// format为auto(默认), gob, json或snapshot; key为AES密钥的十六进制编码, 用于加密的文件
+// gob文件中自定义类型的值需要在本工具中注册后才能读取, 这类文件可以先在应用中转换为JSON
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fredalxin/fcache"
+)
+
+var (
+	addr   = flag.String("addr", "http://localhost:8080", "httpserver address")
+	file   = flag.String("file", "", "read a saved cache file instead of connecting to a server")
+	format = flag.String("format", "auto", "file format: auto, gob, json or snapshot")
+	key    = flag.String("key", "", "hex encoded AES key for encrypted files")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage:\n"+
+			"  fcachectl [-addr URL] keys [PATTERN] | get KEY | set KEY VALUE [TTL] | del KEY | stats\n"+
+			"  fcachectl -file PATH [-format F] [-key HEX] keys [PATTERN] | get KEY | stats | dump\n"+
+			"  fcachectl [-format F] [-key HEX] convert IN OUT gob|json|snapshot\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var err error
+	switch {
+	case args[0] == "convert":
+		err = convert(args[1:])
+	case *file != "":
+		err = fileCommand(args)
+	default:
+		err = remoteCommand(args)
+	}
+	if errors.Is(err, errUsage) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fcachectl:", err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage")
+
+func remoteCommand(args []string) error {
+	base := strings.TrimRight(*addr, "/")
+	switch {
+	case args[0] == "keys" && len(args) <= 2:
+		u := base + "/keys"
+		if len(args) == 2 {
+			u += "?pattern=" + url.QueryEscape(args[1])
+		}
+		return request(http.MethodGet, u, nil, nil)
+	case args[0] == "get" && len(args) == 2:
+		return request(http.MethodGet, base+"/cache/"+url.PathEscape(args[1]), nil, nil)
+	case args[0] == "set" && (len(args) == 3 || len(args) == 4):
+		h := http.Header{}
+		if len(args) == 4 {
+			h.Set("X-Cache-TTL", args[3])
+		}
+		return request(http.MethodPut, base+"/cache/"+url.PathEscape(args[1]), strings.NewReader(args[2]), h)
+	case args[0] == "del" && len(args) == 2:
+		return request(http.MethodDelete, base+"/cache/"+url.PathEscape(args[1]), nil, nil)
+	case args[0] == "stats" && len(args) == 1:
+		return request(http.MethodGet, base+"/stats", nil, nil)
+	}
+	return errUsage
+}
+
+func request(method, u string, body io.Reader, h http.Header) error {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.New("not found")
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if ttl := resp.Header.Get("X-Cache-TTL"); ttl != "" {
+		fmt.Fprintf(os.Stderr, "ttl: %ss\n", ttl)
+	}
+	_, err = io.Copy(os.Stdout, resp.Body)
+	return err
+}
+
+func fileCommand(args []string) error {
+	c, err := open(*file)
+	if err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "keys" && len(args) <= 2:
+		keys := c.Keys()
+		if len(args) == 2 {
+			if keys, err = c.KeysByPattern(args[1]); err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			fmt.Println(k)
+		}
+		return nil
+	case args[0] == "get" && len(args) == 2:
+		v, exp, ok := c.GetWithExpiration(args[1])
+		if !ok {
+			return errors.New("not found")
+		}
+		if !exp.IsZero() {
+			fmt.Fprintf(os.Stderr, "expires: %s\n", exp)
+		}
+		return printValue(v)
+	case args[0] == "stats" && len(args) == 1:
+		return printJSON(map[string]interface{}{
+			"entries":      c.Count(),
+			"memory_bytes": c.MemoryUsage(),
+			"largest":      c.LargestKeys(10),
+		})
+	case args[0] == "dump" && len(args) == 1:
+		return c.SaveJSON(os.Stdout)
+	}
+	return errUsage
+}
+
+func convert(args []string) error {
+	if len(args) != 3 {
+		return errUsage
+	}
+	c, err := open(args[0])
+	if err != nil {
+		return err
+	}
+	switch args[2] {
+	case "gob":
+		return c.SaveToFile(args[1])
+	case "json":
+		return c.SaveJSONToFile(args[1])
+	case "snapshot":
+		return c.SaveSnapshotToFile(args[1])
+	}
+	return errUsage
+}
+
+// 已过期的条目在加载时被忽略
+func open(path string) (*fcache.Cache, error) {
+	var opts []fcache.Option
+	if *key != "" {
+		k, err := hex.DecodeString(*key)
+		if err != nil {
+			return nil, fmt.Errorf("bad key: %v", err)
+		}
+		ci, err := fcache.NewAESCipher(k)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, fcache.WithCipher(ci))
+	}
+	load := map[string]func(c *fcache.Cache) error{
+		"snapshot": func(c *fcache.Cache) error {
+			_, err := c.LoadSnapshotFromFile(path)
+			return err
+		},
+		"gob":  func(c *fcache.Cache) error { return c.LoadFromFile(path) },
+		"json": func(c *fcache.Cache) error { return c.LoadJSONFromFile(path) },
+	}
+	if *format != "auto" {
+		f, ok := load[*format]
+		if !ok {
+			return nil, fmt.Errorf("unknown format %q", *format)
+		}
+		c := fcache.New(opts...)
+		return c, f(c)
+	}
+	var errs []string
+	for _, name := range []string{"snapshot", "gob", "json"} {
+		c := fcache.New(opts...)
+		err := load[name](c)
+		if err == nil {
+			return c, nil
+		}
+		// 快照部分损坏时仍返回已读取的条目
+		if name == "snapshot" && errors.Is(err, fcache.ErrCorruptSnapshot) && c.Count() > 0 {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+			return c, nil
+		}
+		var pe *os.PathError
+		if errors.As(err, &pe) {
+			return nil, err
+		}
+		errs = append(errs, name+": "+err.Error())
+	}
+	return nil, fmt.Errorf("unrecognized file: %s", strings.Join(errs, "; "))
+}
+
+// []byte和string原样输出, 其他类型输出JSON
+func printValue(v interface{}) error {
+	switch b := v.(type) {
+	case []byte:
+		_, err := os.Stdout.Write(b)
+		return err
+	case string:
+		_, err := fmt.Println(b)
+		return err
+	}
+	return printJSON(v)
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,153 @@
+package fcache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// Save和Load使用的序列化格式, 默认为GobCodec
+type Codec interface {
+	Encode(w io.Writer, items map[string]Item) error
+	Decode(r io.Reader) (map[string]Item, error)
+}
+
+// 值的具体类型需要预先gob.Register, 之后保存时间, 供LoadWithClockAdjust使用
+type GobCodec struct{}
+
+// 注册值的具体类型, gob.Register不接受nil
+func register(v interface{}) {
+	if v != nil {
+		gob.Register(v)
+	}
+}
+
+func (GobCodec) Encode(w io.Writer, items map[string]Item) error {
+	enc := gob.NewEncoder(w)
+	for _, v := range items {
+		register(v.Object)
+	}
+	if err := enc.Encode(&items); err != nil {
+		return err
+	}
+	// 记录保存时间, 供LoadWithClockAdjust修正时钟偏差
+	return enc.Encode(time.Now().UnixNano())
+}
+
+func (GobCodec) Decode(r io.Reader) (map[string]Item, error) {
+	items := map[string]Item{}
+	if err := gob.NewDecoder(r).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 格式见SaveJSON, 不需要注册类型, 但读回的值会丢失具体类型
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(w io.Writer, items map[string]Item) error {
+	dump := jsonDump{
+		Version: jsonFormatVersion,
+		SavedAt: time.Now().UnixNano(),
+		Items:   make(map[string]jsonItem, len(items)),
+	}
+	for k, v := range items {
+		dump.Items[k] = jsonItem{
+			Value:      v.Object,
+			Expiration: v.Expiration,
+			Created:    v.Created,
+			TTL:        int64(v.TTL),
+		}
+	}
+	return json.NewEncoder(w).Encode(&dump)
+}
+
+func (JSONCodec) Decode(r io.Reader) (map[string]Item, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var dump jsonDump
+	if err := dec.Decode(&dump); err != nil {
+		return nil, err
+	}
+	items := make(map[string]Item, len(dump.Items))
+	for k, v := range dump.Items {
+		items[k] = Item{
+			Object:     fromJSON(v.Value),
+			Expiration: v.Expiration,
+			Created:    v.Created,
+			TTL:        time.Duration(v.TTL),
+		}
+	}
+	return items, nil
+}
+
+// 与JSONCodec的结构相同, 编码更紧凑且保留[]byte; 整数读回为int64, 浮点数为float64
+type MsgpackCodec struct{}
+
+type msgpackDump struct {
+	Version int                    `msgpack:"version"`
+	SavedAt int64                  `msgpack:"saved_at"`
+	Items   map[string]msgpackItem `msgpack:"items"`
+}
+
+type msgpackItem struct {
+	Value      interface{} `msgpack:"value"`
+	Expiration int64       `msgpack:"expiration"`
+	Created    int64       `msgpack:"created,omitempty"`
+	TTL        int64       `msgpack:"ttl,omitempty"`
+	Version    uint64      `msgpack:"version,omitempty"`
+	Tags       []string    `msgpack:"tags,omitempty"`
+}
+
+func (MsgpackCodec) Encode(w io.Writer, items map[string]Item) error {
+	dump := msgpackDump{
+		Version: jsonFormatVersion,
+		SavedAt: time.Now().UnixNano(),
+		Items:   make(map[string]msgpackItem, len(items)),
+	}
+	for k, v := range items {
+		dump.Items[k] = msgpackItem{
+			Value:      v.Object,
+			Expiration: v.Expiration,
+			Created:    v.Created,
+			TTL:        int64(v.TTL),
+			Version:    v.Version,
+			Tags:       v.Tags,
+		}
+	}
+	return msgpack.NewEncoder(w).Encode(&dump)
+}
+
+func (MsgpackCodec) Decode(r io.Reader) (map[string]Item, error) {
+	var dump msgpackDump
+	if err := msgpack.NewDecoder(r).UseDecodeInterfaceLoose(true).Decode(&dump); err != nil {
+		return nil, err
+	}
+	items := make(map[string]Item, len(dump.Items))
+	for k, v := range dump.Items {
+		items[k] = Item{
+			Object:     v.Value,
+			Expiration: v.Expiration,
+			Created:    v.Created,
+			TTL:        time.Duration(v.TTL),
+			Version:    v.Version,
+			Tags:       v.Tags,
+		}
+	}
+	return items, nil
+}
+
+func (c *Cache) codec() Codec {
+	if cd := c.conf().codec; cd != nil {
+		return cd
+	}
+	return GobCodec{}
+}
+
+// 设置Save和Load使用的格式, nil表示恢复默认的gob
+func (c *Cache) SetCodec(cd Codec) {
+	c.configure(func(cfg *config) { cfg.codec = cd })
+}
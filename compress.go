@@ -0,0 +1,100 @@
+package fcache
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+)
+
+// 压缩存储的[]byte或string值, 读取时透明解压
+type packedValue struct {
+	data []byte
+	str  bool
+}
+
+func (p packedValue) unpack() interface{} {
+	b, err := io.ReadAll(flate.NewReader(bytes.NewReader(p.data)))
+	if err != nil {
+		return nil
+	}
+	if p.str {
+		return string(b)
+	}
+	return b
+}
+
+// 长度达到阈值的[]byte和string值压缩后存储, 压缩无收益时保留原值
+func (c *Cache) pack(v interface{}) interface{} {
+	min := c.conf().compressMin
+	if min <= 0 {
+		return v
+	}
+	var b []byte
+	str := false
+	switch x := v.(type) {
+	case []byte:
+		b = x
+	case string:
+		if len(x) < min {
+			return v
+		}
+		b, str = []byte(x), true
+	default:
+		return v
+	}
+	if len(b) < min {
+		return v
+	}
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write(b)
+	w.Close()
+	if buf.Len() >= len(b) {
+		return v
+	}
+	return packedValue{data: buf.Bytes(), str: str}
+}
+
+func unpack(v interface{}) interface{} {
+	if p, ok := v.(packedValue); ok {
+		return p.unpack()
+	}
+	return v
+}
+
+// 返回解压后的条目
+func (item Item) unpacked() Item {
+	item.Object = unpack(item.Object)
+	return item
+}
+
+// 开启后长度不小于threshold字节的[]byte和string值在内存中压缩存储, 小于等于0表示关闭
+// 之后写入的值生效, 已有的值不受影响; 通过GetRef读取压缩的值时返回的是解压后的副本
+func (c *Cache) SetValueCompression(threshold int) {
+	c.configure(func(cfg *config) { cfg.compressMin = threshold })
+}
+
+// 与Save相同, 输出经过gzip压缩, Load等方法可以直接读取
+func (c *Cache) SaveCompressed(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := c.Save(zw); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (c *Cache) SaveToFileCompressed(file string) error {
+	return c.saveFile(file, c.SaveCompressed)
+}
+
+// gzip压缩的输入透明解压, 其他输入原样返回
+func decompressed(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
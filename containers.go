@@ -0,0 +1,360 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 返回有效的条目, 调用时需持有锁
+func (s *shard) live(k string) (Item, bool) {
+	item, ok := s.items[k]
+	if !ok || s.pending(k) || s.c.expired(item) {
+		return Item{}, false
+	}
+	return item, true
+}
+
+// f返回errUnchanged时不写入
+var errUnchanged = errors.New("unchanged")
+
+// 在写锁内以f的返回值替换k的值并保留过期时间; key不存在时create为true则以d创建, 否则返回ErrKeyNotFound
+// f不能修改传入的值, 读取方可能仍持有它
+func (c *Cache) modify(k string, d time.Duration, create bool, f func(v interface{}, ok bool) (interface{}, error)) error {
+	if err := c.writable(); err != nil {
+		return err
+	}
+	if create {
+		d = c.inheritTTL(k, d)
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	defer c.shrink()
+	defer s.unlock()
+	item, ok := s.live(k)
+	if !ok {
+		if !create {
+			return fmt.Errorf("%w: %s", ErrKeyNotFound, k)
+		}
+		if err := s.waitSpace(context.Background(), k); err != nil {
+			return err
+		}
+		// 等待空间期间可能已被其他goroutine创建
+		item, ok = s.live(k)
+	}
+	v, err := f(unpack(item.Object), ok)
+	if err == errUnchanged {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.set(k, v, d)
+		return nil
+	}
+	item.Object = v
+	item.Version = s.c.nextVersion()
+	s.store(k, item)
+	return nil
+}
+
+// 读取k的值并计入命中统计
+func (c *Cache) read(k string) (interface{}, bool) {
+	s := c.shard(k)
+	s.mu.Lock()
+	v, ok := s.get(k)
+	s.unlock()
+	c.recordGet(ok)
+	return v, ok
+}
+
+func listOf(k string, v interface{}, ok bool) ([]interface{}, error) {
+	if !ok {
+		return nil, nil
+	}
+	l, isList := v.([]interface{})
+	if !isList {
+		return nil, fmt.Errorf("%w: %s is not a list", ErrTypeMismatch, k)
+	}
+	return l, nil
+}
+
+// 在列表末尾追加, 返回追加后的长度; key不存在时以过期时间d创建[]interface{}
+func (c *Cache) RPush(k string, d time.Duration, vals ...interface{}) (int, error) {
+	n := 0
+	err := c.modify(k, d, true, func(v interface{}, ok bool) (interface{}, error) {
+		l, err := listOf(k, v, ok)
+		if err != nil {
+			return nil, err
+		}
+		nl := make([]interface{}, 0, len(l)+len(vals))
+		nl = append(append(nl, l...), vals...)
+		n = len(nl)
+		return nl, nil
+	})
+	return n, err
+}
+
+// 在列表头部插入, vals按参数顺序排列在原有元素之前
+func (c *Cache) LPush(k string, d time.Duration, vals ...interface{}) (int, error) {
+	n := 0
+	err := c.modify(k, d, true, func(v interface{}, ok bool) (interface{}, error) {
+		l, err := listOf(k, v, ok)
+		if err != nil {
+			return nil, err
+		}
+		nl := make([]interface{}, 0, len(l)+len(vals))
+		nl = append(append(nl, vals...), l...)
+		n = len(nl)
+		return nl, nil
+	})
+	return n, err
+}
+
+// 弹出第一个元素, 列表为空或key不存在时返回false
+func (c *Cache) LPop(k string) (interface{}, bool, error) {
+	return c.pop(k, true)
+}
+
+func (c *Cache) RPop(k string) (interface{}, bool, error) {
+	return c.pop(k, false)
+}
+
+// 弹出后列表为空时保留空列表
+func (c *Cache) pop(k string, front bool) (interface{}, bool, error) {
+	var x interface{}
+	popped := false
+	err := c.modify(k, 0, false, func(v interface{}, ok bool) (interface{}, error) {
+		l, err := listOf(k, v, ok)
+		if err != nil {
+			return nil, err
+		}
+		if len(l) == 0 {
+			return nil, errUnchanged
+		}
+		popped = true
+		if front {
+			x = l[0]
+			return append([]interface{}(nil), l[1:]...), nil
+		}
+		x = l[len(l)-1]
+		return append([]interface{}(nil), l[:len(l)-1]...), nil
+	})
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	return x, popped, err
+}
+
+// 按Redis的LRANGE语义返回[start, stop]范围内的元素, 负数表示从末尾倒数
+func (c *Cache) LRange(k string, start, stop int) ([]interface{}, error) {
+	v, ok := c.read(k)
+	l, err := listOf(k, v, ok)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	n := len(l)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return []interface{}{}, nil
+	}
+	return append([]interface{}(nil), l[start:stop+1]...), nil
+}
+
+func (c *Cache) LLen(k string) (int, error) {
+	v, ok := c.read(k)
+	l, err := listOf(k, v, ok)
+	return len(l), err
+}
+
+func setOf(k string, v interface{}, ok bool) (map[string]struct{}, error) {
+	if !ok {
+		return nil, nil
+	}
+	m, isSet := v.(map[string]struct{})
+	if !isSet {
+		return nil, fmt.Errorf("%w: %s is not a set", ErrTypeMismatch, k)
+	}
+	return m, nil
+}
+
+// 添加成员, 返回新增的数量; key不存在时以过期时间d创建map[string]struct{}
+func (c *Cache) SAdd(k string, d time.Duration, members ...string) (int, error) {
+	added := 0
+	err := c.modify(k, d, true, func(v interface{}, ok bool) (interface{}, error) {
+		m, err := setOf(k, v, ok)
+		if err != nil {
+			return nil, err
+		}
+		nm := make(map[string]struct{}, len(m)+len(members))
+		for x := range m {
+			nm[x] = struct{}{}
+		}
+		for _, x := range members {
+			if _, ok := nm[x]; !ok {
+				nm[x] = struct{}{}
+				added++
+			}
+		}
+		return nm, nil
+	})
+	return added, err
+}
+
+// 删除成员, 返回删除的数量; key不存在时返回0
+func (c *Cache) SRem(k string, members ...string) (int, error) {
+	removed := 0
+	err := c.modify(k, 0, false, func(v interface{}, ok bool) (interface{}, error) {
+		m, err := setOf(k, v, ok)
+		if err != nil {
+			return nil, err
+		}
+		nm := make(map[string]struct{}, len(m))
+		for x := range m {
+			nm[x] = struct{}{}
+		}
+		for _, x := range members {
+			if _, ok := nm[x]; ok {
+				delete(nm, x)
+				removed++
+			}
+		}
+		if removed == 0 {
+			return nil, errUnchanged
+		}
+		return nm, nil
+	})
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	return removed, err
+}
+
+// 按字典序返回所有成员
+func (c *Cache) SMembers(k string) ([]string, error) {
+	v, ok := c.read(k)
+	m, err := setOf(k, v, ok)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(m))
+	for x := range m {
+		members = append(members, x)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (c *Cache) SIsMember(k, member string) (bool, error) {
+	v, ok := c.read(k)
+	m, err := setOf(k, v, ok)
+	_, found := m[member]
+	return found, err
+}
+
+func (c *Cache) SCard(k string) (int, error) {
+	v, ok := c.read(k)
+	m, err := setOf(k, v, ok)
+	return len(m), err
+}
+
+func hashOf(k string, v interface{}, ok bool) (map[string]interface{}, error) {
+	if !ok {
+		return nil, nil
+	}
+	m, isHash := v.(map[string]interface{})
+	if !isHash {
+		return nil, fmt.Errorf("%w: %s is not a map[string]interface{}", ErrTypeMismatch, k)
+	}
+	return m, nil
+}
+
+// 设置字段, 返回是否为新增字段; key不存在时以默认过期时间创建map[string]interface{}
+func (c *Cache) HSet(k, field string, v interface{}) (bool, error) {
+	added := false
+	err := c.modify(k, DefaultExpiration, true, func(old interface{}, ok bool) (interface{}, error) {
+		m, err := hashOf(k, old, ok)
+		if err != nil {
+			return nil, err
+		}
+		nm := make(map[string]interface{}, len(m)+1)
+		for f, x := range m {
+			nm[f] = x
+		}
+		_, exists := nm[field]
+		added = !exists
+		nm[field] = v
+		return nm, nil
+	})
+	return added, err
+}
+
+func (c *Cache) HGet(k, field string) (interface{}, bool, error) {
+	v, ok := c.read(k)
+	m, err := hashOf(k, v, ok)
+	x, found := m[field]
+	return x, found, err
+}
+
+// 删除字段, 返回删除的数量; key不存在时返回0
+func (c *Cache) HDel(k string, fields ...string) (int, error) {
+	removed := 0
+	err := c.modify(k, 0, false, func(old interface{}, ok bool) (interface{}, error) {
+		m, err := hashOf(k, old, ok)
+		if err != nil {
+			return nil, err
+		}
+		nm := make(map[string]interface{}, len(m))
+		for f, x := range m {
+			nm[f] = x
+		}
+		for _, f := range fields {
+			if _, ok := nm[f]; ok {
+				delete(nm, f)
+				removed++
+			}
+		}
+		if removed == 0 {
+			return nil, errUnchanged
+		}
+		return nm, nil
+	})
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	return removed, err
+}
+
+// 返回所有字段的副本, 字段的值本身不复制
+func (c *Cache) HGetAll(k string) (map[string]interface{}, error) {
+	v, ok := c.read(k)
+	m, err := hashOf(k, v, ok)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	all := make(map[string]interface{}, len(m))
+	for f, x := range m {
+		all[f] = x
+	}
+	return all, nil
+}
+
+func (c *Cache) HLen(k string) (int, error) {
+	v, ok := c.read(k)
+	m, err := hashOf(k, v, ok)
+	return len(m), err
+}
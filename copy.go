@@ -0,0 +1,39 @@
+package fcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+)
+
+// 开启copyOnRead时返回值的副本, 调用方修改返回值不会影响缓存中的值
+func (c *Cache) copyValue(v interface{}) interface{} {
+	cfg := c.conf()
+	if !cfg.copyOnRead || v == nil {
+		return v
+	}
+	if cfg.cloner != nil {
+		return cfg.cloner(v)
+	}
+	return gobClone(v)
+}
+
+// 通过gob编解码深拷贝, 不可编码的值(如含有chan或func)原样返回
+// gob不保留nil指针和空的map/slice与nil的区别
+func gobClone(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case []byte:
+		return append([]byte(nil), x...)
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).EncodeValue(reflect.ValueOf(v)); err != nil {
+		return v
+	}
+	p := reflect.New(reflect.TypeOf(v))
+	if err := gob.NewDecoder(&buf).DecodeValue(p); err != nil {
+		return v
+	}
+	return p.Elem().Interface()
+}
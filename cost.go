@@ -0,0 +1,41 @@
+package fcache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+func (item Item) cost() int64 {
+	if item.Cost <= 0 {
+		return 1
+	}
+	return item.Cost
+}
+
+// 与Set相同, 条目按cost计入开销预算; cost超过整个预算时返回包装了ErrCacheFull的错误
+func (c *Cache) SetWithCost(k string, v interface{}, d time.Duration, cost int64) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if max := c.conf().maxCost; max > 0 && cost > max {
+		return fmt.Errorf("%w: %s costs %d, budget %d", ErrCacheFull, k, cost, max)
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	return c.setItem(context.Background(), k, Item{Object: v, Cost: cost}, d)
+}
+
+// 设置开销预算, 所有条目的开销之和超出时按淘汰策略淘汰, 小于等于0表示不限制
+// 未指定开销的条目按1计算, 此时预算相当于条目数上限
+func (c *Cache) SetMaxCost(n int64) {
+	c.configure(func(cfg *config) { cfg.maxCost = n })
+	c.shrink()
+}
+
+// 返回所有条目的开销之和
+func (c *Cache) TotalCost() int64 {
+	return atomic.LoadInt64(&c.cost)
+}
@@ -0,0 +1,83 @@
+package fcache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 在原值上累加delta并保留过期时间, 调用时需持有写锁
+func (s *shard) incr(k string, delta interface{}) (interface{}, error) {
+	item, ok := s.live(k)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, k)
+	}
+	return s.add(k, item, delta)
+}
+
+// 在live返回的item上累加delta, 调用时需持有写锁
+func (s *shard) add(k string, item Item, delta interface{}) (interface{}, error) {
+	v, ok := addNumber(item.Object, delta)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a number", ErrTypeMismatch, k)
+	}
+	item.Object = v
+	item.Version = s.c.nextVersion()
+	s.store(k, item)
+	return v, nil
+}
+
+func (c *Cache) incr(k string, delta interface{}) (interface{}, error) {
+	if err := c.writable(); err != nil {
+		return nil, err
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	v, err := s.incr(k, delta)
+	s.unlock()
+	c.shrink()
+	return v, err
+}
+
+// 对任意整数或浮点类型的值原子地加n, 返回与原值同类型的新值
+func (c *Cache) Increment(k string, n int64) (interface{}, error) {
+	return c.incr(k, n)
+}
+
+func (c *Cache) Decrement(k string, n int64) (interface{}, error) {
+	return c.incr(k, -n)
+}
+
+// 只能用于浮点类型的值
+func (c *Cache) IncrementFloat(k string, n float64) (interface{}, error) {
+	return c.incr(k, n)
+}
+
+func (c *Cache) DecrementFloat(k string, n float64) (interface{}, error) {
+	return c.incr(k, -n)
+}
+
+// 与Increment相同, key不存在时以int64(n)和过期时间d创建
+func (c *Cache) IncrBy(k string, n int64, d time.Duration) (interface{}, error) {
+	if err := c.writable(); err != nil {
+		return nil, err
+	}
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	defer c.shrink()
+	defer s.unlock()
+	// 是否存在只判断一次, 避免判断后恰好过期而返回ErrKeyNotFound
+	if item, ok := s.live(k); ok {
+		return s.add(k, item, n)
+	}
+	if err := s.waitSpace(context.Background(), k); err != nil {
+		return nil, err
+	}
+	// 等待空间期间可能已被其他goroutine创建
+	if item, ok := s.live(k); ok {
+		return s.add(k, item, n)
+	}
+	s.set(k, n, d)
+	return n, nil
+}
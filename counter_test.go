@@ -0,0 +1,58 @@
+package fcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 每次读取后前进step, 用于让过期恰好发生在两次读取之间
+type steppingClock struct {
+	*FakeClock
+	step time.Duration
+}
+
+func (c steppingClock) Now() time.Time {
+	now := c.FakeClock.Now()
+	c.FakeClock.Advance(c.step)
+	return now
+}
+
+func TestIncrBy(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithClock(clock), WithGCInterval(0))
+	c.Set("n", int64(1), NoExpiration)
+	c.Set("expired", int64(1), time.Second)
+	c.Set("str", "x", NoExpiration)
+	clock.Advance(2 * time.Second)
+
+	tests := []struct {
+		key     string
+		want    interface{}
+		wantErr error
+	}{
+		{"n", int64(3), nil},
+		{"expired", int64(2), nil},
+		{"missing", int64(2), nil},
+		{"str", nil, ErrTypeMismatch},
+	}
+	for _, tt := range tests {
+		v, err := c.IncrBy(tt.key, 2, NoExpiration)
+		if !errors.Is(err, tt.wantErr) || v != tt.want {
+			t.Errorf("IncrBy(%s) = %v, %v; want %v, %v", tt.key, v, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// 在IncrBy执行期间过期的key被重新创建而不是返回ErrKeyNotFound
+func TestIncrByExpiresDuringCall(t *testing.T) {
+	for ttl := time.Nanosecond; ttl <= 50*time.Nanosecond; ttl += time.Nanosecond {
+		clock := steppingClock{NewFakeClock(time.Unix(1000, 0)), time.Nanosecond}
+		c := New(WithClock(clock), WithGCInterval(0))
+		c.Set("k", int64(10), ttl)
+		v, err := c.IncrBy("k", 1, time.Hour)
+		if err != nil || (v != int64(11) && v != int64(1)) {
+			t.Fatalf("ttl %v: IncrBy = %v, %v", ttl, v, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package fcache
+
+import (
+	"expvar"
+	"fmt"
+	"sort"
+)
+
+// 以name发布统计数据到expvar, 可通过/debug/vars查看; name已被占用时返回错误
+func (c *Cache) RegisterExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("Expvar %s already registered", name)
+	}
+	c.SetMemoryTracking(true)
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		st := c.Stats()
+		return map[string]interface{}{
+			"hits":         st.Hits,
+			"misses":       st.Misses,
+			"hit_ratio":    st.HitRatio(),
+			"sets":         st.Sets,
+			"deletes":      st.Deletes,
+			"evictions":    st.Evictions,
+			"expired":      st.Expired,
+			"gc_runs":      st.GCRuns,
+			"gc_seconds":   st.GCTime.Seconds(),
+			"entries":      c.Count(),
+			"memory_bytes": c.MemoryUsage(),
+			"loads":        st.Loads,
+			"load_errors":  st.LoadErrors,
+			"load_seconds": st.LoadTime.Seconds(),
+			"load_panics":  st.LoadPanics,
+			"coalesced":    st.Coalesced,
+			"in_flight":    c.InFlight(),
+			"pinned":       st.Pinned,
+		}
+	}))
+	return nil
+}
+
+type KeyStat struct {
+	Key  string
+	Size int64
+	Hits uint64
+}
+
+// 估算内存占用最大的n个key, 需要设置内存上限或开启内存统计, 见SetMemoryTracking
+func (c *Cache) LargestKeys(n int) []KeyStat {
+	return c.topKeys(n, func(a, b KeyStat) bool { return a.Size > b.Size })
+}
+
+// 自最近一次写入以来读取次数最多的n个key
+func (c *Cache) HottestKeys(n int) []KeyStat {
+	return c.topKeys(n, func(a, b KeyStat) bool { return a.Hits > b.Hits })
+}
+
+func (c *Cache) topKeys(n int, less func(a, b KeyStat) bool) []KeyStat {
+	var all []KeyStat
+	for _, s := range c.shards {
+		for _, e := range s.snapshot() {
+			all = append(all, KeyStat{Key: e.Key, Size: e.Item.size, Hits: e.Item.hits})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return less(all[i], all[j]) })
+	if n >= 0 && len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
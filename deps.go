@@ -0,0 +1,107 @@
+package fcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 与Set相同, 并声明k依赖parents: 任一parent被删除, 修改或过期时k被删除, 依赖k的key也随之删除
+// 重新写入时依赖被替换, 不带依赖写入会清除原有依赖; parent被淘汰时不影响依赖它的key
+func (c *Cache) SetWithDeps(k string, v interface{}, d time.Duration, parents ...string) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	return c.setItem(context.Background(), k, Item{Object: v, Deps: parents}, d)
+}
+
+// 跨shard的依赖关系, 只在持有shard写锁时修改, 不在持有mu时加shard锁
+type depGraph struct {
+	mu       sync.Mutex
+	children map[string]map[string]struct{}
+	// 没有依赖关系时写入不加mu
+	parents int64
+}
+
+func (g *depGraph) link(k string, parents []string) {
+	if len(parents) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.children == nil {
+		g.children = map[string]map[string]struct{}{}
+	}
+	for _, p := range parents {
+		keys, ok := g.children[p]
+		if !ok {
+			keys = map[string]struct{}{}
+			g.children[p] = keys
+		}
+		keys[k] = struct{}{}
+	}
+	atomic.StoreInt64(&g.parents, int64(len(g.children)))
+}
+
+func (g *depGraph) unlink(k string, parents []string) {
+	if len(parents) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, p := range parents {
+		keys := g.children[p]
+		delete(keys, k)
+		if len(keys) == 0 {
+			delete(g.children, p)
+		}
+	}
+	atomic.StoreInt64(&g.parents, int64(len(g.children)))
+}
+
+func (g *depGraph) hasDependents(k string) bool {
+	if atomic.LoadInt64(&g.parents) == 0 {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.children[k]) > 0
+}
+
+// 取出并移除依赖parents的key
+func (g *depGraph) take(parents []string) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var keys []string
+	for _, p := range parents {
+		for k := range g.children[p] {
+			keys = append(keys, k)
+		}
+		delete(g.children, p)
+	}
+	atomic.StoreInt64(&g.parents, int64(len(g.children)))
+	return keys
+}
+
+// k发生变化, 在unlock之后删除依赖它的key; 调用时需持有写锁
+func (s *shard) changed(k string) {
+	if s.c.deps.hasDependents(k) {
+		s.invalid = append(s.invalid, k)
+	}
+}
+
+// 删除依赖parents的key, 被删除的key在各自shard的unlock中继续删除依赖它们的key
+func (c *Cache) invalidate(parents []string) {
+	for _, k := range c.deps.take(parents) {
+		s := c.shard(k)
+		s.mu.Lock()
+		if s.deleteKey(k) {
+			atomic.AddUint64(&c.stats.deletes, 1)
+		}
+		s.unlock()
+	}
+}
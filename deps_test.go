@@ -0,0 +1,55 @@
+package fcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetWithDeps(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Cache, clock *FakeClock)
+	}{
+		{"delete", func(c *Cache, clock *FakeClock) { c.Delete("parent") }},
+		{"overwrite", func(c *Cache, clock *FakeClock) { c.Set("parent", 2, NoExpiration) }},
+		{"expire", func(c *Cache, clock *FakeClock) {
+			c.Set("parent", 2, time.Second)
+			// 重新写入本身会删除依赖, 重新建立后再等待过期
+			c.SetWithDeps("child", 1, NoExpiration, "parent")
+			c.SetWithDeps("grandchild", 1, NoExpiration, "child")
+			clock.Advance(2 * time.Second)
+			c.RunGC()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			c := New(WithClock(clock), WithGCInterval(0))
+			c.Set("parent", 1, NoExpiration)
+			c.SetWithDeps("child", 1, NoExpiration, "parent")
+			c.SetWithDeps("grandchild", 1, NoExpiration, "child")
+			c.SetWithDeps("unrelated", 1, NoExpiration, "other")
+			tt.change(c, clock)
+			for _, k := range []string{"child", "grandchild"} {
+				if _, ok := c.Get(k); ok {
+					t.Errorf("%s survived", k)
+				}
+			}
+			if _, ok := c.Get("unrelated"); !ok {
+				t.Error("unrelated key deleted")
+			}
+		})
+	}
+}
+
+// 不带依赖重新写入后不再随parent删除
+func TestSetWithDepsReplaced(t *testing.T) {
+	c := New(WithGCInterval(0))
+	c.Set("parent", 1, NoExpiration)
+	c.SetWithDeps("child", 1, NoExpiration, "parent")
+	c.Set("child", 2, NoExpiration)
+	c.Delete("parent")
+	if v, ok := c.Get("child"); !ok || v != 2 {
+		t.Fatalf("Get(child) = %v, %v", v, ok)
+	}
+}
@@ -0,0 +1,284 @@
+package fcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var diskBucket = []byte("fcache")
+
+// 写入在后台每隔diskCommitInterval批量提交一次, 崩溃时最多丢失最后一批
+const diskCommitInterval = 100 * time.Millisecond
+
+type diskOp struct {
+	op    byte
+	key   string
+	shard int
+	data  []byte
+}
+
+type diskStore struct {
+	c       *Cache
+	db      *bolt.DB
+	mu      sync.Mutex
+	pending []diskOp
+	err     error
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// 见EnableDiskBackend; 打开失败时不启用, 错误在Close时返回
+func WithDiskBackend(path string) Option {
+	return func(o *options) { o.diskPath = path }
+}
+
+// 每次写入和删除同时写入path处的bbolt数据库, 超出容量被淘汰的条目保留在磁盘上
+// 重启后不整体加载, Get未命中时从磁盘读取并放回内存; Count, Keys和Range等只反映内存中的条目
+// 需在缓存开始使用之前调用; 数据库文件只允许所有者读写, 设置了Cipher时每个值单独加密
+func (c *Cache) EnableDiskBackend(path string) error {
+	if c.closed() {
+		return ErrClosed
+	}
+	if c.disk != nil {
+		return fmt.Errorf("Disk backend already enabled")
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(diskBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return err
+	}
+	d := &diskStore{
+		c:    c,
+		db:   db,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	c.disk = d
+	go d.loop()
+	return nil
+}
+
+// 调用时需持有对应shard的写锁, 保证同一key的写入顺序与内存中一致
+func (d *diskStore) append(op byte, k string, item Item) {
+	rec := diskOp{op: op, key: k}
+	if op == aofSet {
+		item = item.unpacked()
+		register(item.Object)
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&item); err != nil {
+			d.fail(err)
+			return
+		}
+		data, err := d.c.sealRecord(buf.Bytes())
+		if err != nil {
+			d.fail(err)
+			return
+		}
+		rec.data = data
+	}
+	d.push(rec)
+}
+
+// 清空第i个shard在磁盘上的条目
+func (d *diskStore) flushShard(i int) {
+	d.push(diskOp{op: aofFlush, shard: i})
+}
+
+func (d *diskStore) push(op diskOp) {
+	d.mu.Lock()
+	if !d.closed {
+		d.pending = append(d.pending, op)
+	}
+	d.mu.Unlock()
+}
+
+func (d *diskStore) loop() {
+	defer close(d.done)
+	ticker := time.NewTicker(diskCommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.commit()
+		case <-d.stop:
+			d.commit()
+			return
+		}
+	}
+}
+
+func (d *diskStore) commit() {
+	d.mu.Lock()
+	ops := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+	if len(ops) == 0 {
+		return
+	}
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(diskBucket)
+		// 连续的清空合并为一次遍历, Flush清空所有shard时直接重建bucket
+		flushed := map[int]bool{}
+		wipe := func() error {
+			if len(flushed) == 0 {
+				return nil
+			}
+			var err error
+			if len(flushed) == len(d.c.shards) {
+				if err = tx.DeleteBucket(diskBucket); err == nil {
+					b, err = tx.CreateBucketIfNotExists(diskBucket)
+				}
+			} else {
+				err = d.wipe(b, flushed)
+			}
+			flushed = map[int]bool{}
+			return err
+		}
+		for _, op := range ops {
+			if op.op == aofFlush {
+				flushed[op.shard] = true
+				continue
+			}
+			if err := wipe(); err != nil {
+				return err
+			}
+			var err error
+			switch op.op {
+			case aofSet:
+				err = b.Put([]byte(op.key), op.data)
+			case aofDelete:
+				err = b.Delete([]byte(op.key))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return wipe()
+	})
+	if err != nil {
+		d.fail(err)
+	}
+}
+
+// 错误在Close时返回
+func (d *diskStore) fail(err error) {
+	d.mu.Lock()
+	d.err = err
+	d.mu.Unlock()
+	d.c.warn("fcache: disk backend write failed", "err", err)
+}
+
+// 删除属于shards中各shard的所有key
+func (d *diskStore) wipe(b *bolt.Bucket, shards map[int]bool) error {
+	var keys [][]byte
+	b.ForEach(func(k, v []byte) error {
+		if shards[d.c.shardIndex(string(k))] {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 先查找尚未提交的写入, 再读取数据库
+func (d *diskStore) read(k string) (Item, bool) {
+	var data []byte
+	found := false
+	d.mu.Lock()
+	for i := len(d.pending) - 1; i >= 0 && !found; i-- {
+		op := d.pending[i]
+		switch {
+		case op.op == aofFlush && op.shard == d.c.shardIndex(k):
+			d.mu.Unlock()
+			return Item{}, false
+		case op.key == k && op.op == aofDelete:
+			d.mu.Unlock()
+			return Item{}, false
+		case op.key == k && op.op == aofSet:
+			data, found = op.data, true
+		}
+	}
+	d.mu.Unlock()
+	if !found {
+		d.db.View(func(tx *bolt.Tx) error {
+			if v := tx.Bucket(diskBucket).Get([]byte(k)); v != nil {
+				data, found = append([]byte(nil), v...), true
+			}
+			return nil
+		})
+	}
+	if !found {
+		return Item{}, false
+	}
+	data, err := d.c.openRecord(data)
+	if err != nil {
+		return Item{}, false
+	}
+	var item Item
+	if gob.NewDecoder(bytes.NewReader(data)).Decode(&item) != nil {
+		return Item{}, false
+	}
+	return item, true
+}
+
+// 将从磁盘或内存映射读到的k放回内存, valid不为nil时在写锁内确认读取之后k没有被写入或删除
+// 达到容量上限且不淘汰时只返回值而不放回
+func (c *Cache) hydrate(s *shard, k string, item Item, valid func() bool) (interface{}, bool) {
+	if c.expired(item) {
+		return nil, false
+	}
+	s.mu.Lock()
+	if _, exists := s.items[k]; !exists {
+		if valid != nil && !valid() {
+			s.unlock()
+			return nil, false
+		}
+		if s.full(k) && c.conf().overflow != EvictOnFull || s.waitSpace(context.Background(), k) != nil {
+			s.unlock()
+			return c.copyValue(item.Object), true
+		}
+		s.hydrating = true
+		s.store(k, item)
+		s.hydrating = false
+	}
+	v, ok := s.get(k)
+	s.unlock()
+	c.shrink()
+	return v, ok
+}
+
+func (d *diskStore) close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return d.err
+	}
+	d.mu.Unlock()
+	close(d.stop)
+	<-d.done
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	if err := d.db.Close(); err != nil && d.err == nil {
+		d.err = err
+	}
+	return d.err
+}
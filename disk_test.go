@@ -0,0 +1,89 @@
+package fcache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDiskBackendCipher(t *testing.T) {
+	ci, err := NewAESCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "disk.db")
+	c := New(WithGCInterval(0))
+	c.SetCipher(ci)
+	if err := c.EnableDiskBackend(path); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("k", "secret-payload", NoExpiration)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret-payload")) {
+		t.Error("value stored in plaintext")
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v, want 0600", fi.Mode().Perm())
+	}
+
+	tests := []struct {
+		name   string
+		cipher Cipher
+		want   bool
+	}{
+		{"with cipher", ci, true},
+		{"without cipher", nil, false},
+	}
+	for _, tt := range tests {
+		c := New(WithGCInterval(0))
+		c.SetCipher(tt.cipher)
+		if err := c.EnableDiskBackend(path); err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := c.Get("k"); ok != tt.want || (ok && v != "secret-payload") {
+			t.Errorf("%s: Get = %v, %v", tt.name, v, ok)
+		}
+		c.Close()
+	}
+}
+
+// Flush清空所有shard在磁盘上的条目, 之后的写入保留
+func TestDiskBackendFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.db")
+	c := New(WithShards(4), WithGCInterval(0))
+	if err := c.EnableDiskBackend(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		c.Set(k, k, NoExpiration)
+	}
+	c.Flush()
+	c.Set("after", 1, NoExpiration)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = New(WithShards(4), WithGCInterval(0))
+	if err := c.EnableDiskBackend(path); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		if _, ok := c.Get(k); ok {
+			t.Errorf("%s survived Flush", k)
+		}
+	}
+	if _, ok := c.Get("after"); !ok {
+		t.Error("key written after Flush lost")
+	}
+}
@@ -0,0 +1,58 @@
+package fcache
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// 按XFetch算法提前过期: 临近过期时Get以一定概率视为未命中, 由该调用方提前重新加载, 避免到期时集中加载
+// 概率随剩余存活时间减少和加载耗时增加而增大, beta越大越早, 通常为1; 小于等于0时关闭
+// 加载耗时取该key上一次由loader加载的耗时, 不是由loader写入的key取所有加载的平均耗时
+func WithEarlyExpiration(beta float64) Option {
+	return func(o *options) { o.earlyBeta = beta }
+}
+
+func (c *Cache) SetEarlyExpiration(beta float64) {
+	c.configure(func(cfg *config) { cfg.earlyBeta = beta })
+}
+
+// 返回true表示应视为未命中并重新加载
+func (c *Cache) expiresEarly(k string) bool {
+	beta := c.conf().earlyBeta
+	if beta <= 0 {
+		return false
+	}
+	s := c.shard(k)
+	s.mu.RLock()
+	item, ok := s.items[k]
+	s.mu.RUnlock()
+	if !ok || item.Expiration == 0 || item.pinned {
+		return false
+	}
+	delta := item.loadTime
+	if delta == 0 {
+		if n := atomic.LoadUint64(&c.stats.loads); n > 0 {
+			delta = int64(atomic.LoadUint64(&c.stats.loadNanos) / n)
+		}
+	}
+	if delta <= 0 {
+		return false
+	}
+	// now - delta*beta*ln(rand) >= expiry, rand取(0, 1]
+	gap := -float64(delta) * beta * math.Log(1-rand.Float64())
+	return gap >= float64(item.Expiration-c.nowNano())
+}
+
+// 写入loader的结果并记录加载耗时
+func (c *Cache) setLoaded(ctx context.Context, k string, v interface{}, d, took time.Duration) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	return c.setItem(ctx, k, Item{Object: v, loadTime: int64(took)}, d)
+}
@@ -0,0 +1,173 @@
+package fcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 加密写入文件的缓存数据, 设置后通过*ToFile保存和*FromFile加载的文件会被加密和解密
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// 加密文件的头部标识
+const encryptedMagic = "FCEN"
+
+type aesGCM struct {
+	aead cipher.AEAD
+}
+
+// key长度为16, 24或32字节, 分别对应AES-128, AES-192和AES-256
+func NewAESCipher(key []byte) (Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCM{aead: aead}, nil
+}
+
+// 随机nonce放在密文之前
+func (a *aesGCM) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize(), a.aead.NonceSize()+len(plaintext)+a.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return a.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (a *aesGCM) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := a.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, fmt.Errorf("Ciphertext too short")
+	}
+	return a.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
+
+// 不影响Save等直接写入io.Writer的方法; 之后写入磁盘后端和append log的记录也会被加密
+func (c *Cache) SetCipher(ci Cipher) {
+	c.configure(func(cfg *config) { cfg.cipher = ci })
+}
+
+// 设置了Cipher时先在内存中完整输出, 加密后再写入w
+func (c *Cache) encryptTo(w io.Writer, save func(io.Writer) error) error {
+	ci := c.conf().cipher
+	if ci == nil {
+		return save(w)
+	}
+	var buf bytes.Buffer
+	if err := save(&buf); err != nil {
+		return err
+	}
+	data, err := ci.Encrypt(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(w, encryptedMagic); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// 加密的输入解密后返回, 未设置Cipher时其他输入原样返回
+func (c *Cache) decryptFrom(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(encryptedMagic))
+	ci := c.conf().cipher
+	if err != nil || string(magic) != encryptedMagic {
+		if ci != nil {
+			return nil, fmt.Errorf("%w: file is not encrypted but a Cipher is set", ErrIncompatibleSnapshot)
+		}
+		return br, nil
+	}
+	if ci == nil {
+		return nil, fmt.Errorf("%w: file is encrypted but no Cipher is set", ErrIncompatibleSnapshot)
+	}
+	br.Discard(len(encryptedMagic))
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = ci.Decrypt(data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// 设置了Cipher时加密单条记录并加上encryptedMagic, 用于磁盘后端和append log
+// gob编码的记录以较长的类型定义开头, 不会与encryptedMagic混淆
+func (c *Cache) sealRecord(data []byte) ([]byte, error) {
+	ci := c.conf().cipher
+	if ci == nil {
+		return data, nil
+	}
+	sealed, err := ci.Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(encryptedMagic), sealed...), nil
+}
+
+// 解密sealRecord加密的记录, 未加密的记录原样返回
+func (c *Cache) openRecord(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		return data, nil
+	}
+	ci := c.conf().cipher
+	if ci == nil {
+		return nil, fmt.Errorf("%w: record is encrypted but no Cipher is set", ErrIncompatibleSnapshot)
+	}
+	data, err := ci.Decrypt(data[len(encryptedMagic):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	return data, nil
+}
+
+func (c *Cache) saveFile(file string, save func(io.Writer) error) (err error) {
+	_, span := c.startSpan(context.Background(), "fcache.save", attribute.String("fcache.file", file))
+	defer func() { endSpan(span, err) }()
+	// 加密的文件只允许所有者读写
+	perm := os.FileMode(0666)
+	if c.conf().cipher != nil {
+		perm = 0600
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err = c.encryptTo(f, save); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (c *Cache) loadFile(file string, load func(io.Reader) error) (err error) {
+	_, span := c.startSpan(context.Background(), "fcache.load_file", attribute.String("fcache.file", file))
+	defer func() { endSpan(span, err) }()
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := c.decryptFrom(f)
+	if err != nil {
+		return err
+	}
+	return load(r)
+}
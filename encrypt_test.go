@@ -0,0 +1,58 @@
+package fcache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCipherFiles(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	secret := filepath.Join(dir, "secret")
+	ci, err := NewAESCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(WithGCInterval(0))
+	c.Set("a", 1, NoExpiration)
+	if err := c.SaveToFile(plain); err != nil {
+		t.Fatal(err)
+	}
+	c.SetCipher(ci)
+	if err := c.SaveToFile(secret); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(secret); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("encrypted file mode = %v, want 0600", fi.Mode().Perm())
+	}
+
+	tests := []struct {
+		name    string
+		cipher  Cipher
+		file    string
+		wantErr error
+	}{
+		{"plain without cipher", nil, plain, nil},
+		{"encrypted with cipher", ci, secret, nil},
+		{"encrypted without cipher", nil, secret, ErrIncompatibleSnapshot},
+		{"plain with cipher", ci, plain, ErrIncompatibleSnapshot},
+	}
+	for _, tt := range tests {
+		c := New(WithGCInterval(0))
+		c.SetCipher(tt.cipher)
+		err := c.LoadFromFile(tt.file)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if _, ok := c.Get("a"); ok != (tt.wantErr == nil) {
+			t.Errorf("%s: Get a = %v", tt.name, ok)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package fcache
+
+import "errors"
+
+// 返回的错误以%w包装了以下错误和出错的key, 可用errors.Is判断
+var (
+	// Add写入的key已存在
+	ErrKeyExists = errors.New("fcache: key exists")
+	// Update, Increment等操作的key不存在或已过期
+	ErrKeyNotFound = errors.New("fcache: key not found")
+	// Increment等操作的值不是数值或类型不匹配
+	ErrTypeMismatch = errors.New("fcache: type mismatch")
+	// RejectOnFull策略下达到容量上限
+	ErrCacheFull = errors.New("fcache: cache full")
+	// 缓存已关闭
+	ErrClosed = errors.New("fcache: cache closed")
+	// 开启strictTTL时传入了含义不明确的存活时间
+	ErrAmbiguousTTL = errors.New("fcache: ambiguous ttl")
+	// 不是快照文件或版本不支持
+	ErrIncompatibleSnapshot = errors.New("fcache: incompatible snapshot")
+	// 快照部分记录损坏或被截断
+	ErrCorruptSnapshot = errors.New("fcache: corrupt snapshot")
+	// Tx多次重试后仍与其他写入冲突
+	ErrConflict = errors.New("fcache: transaction conflict")
+	// SetIfVersionErr传入的版本号与当前版本不一致
+	ErrVersionMismatch = errors.New("fcache: version mismatch")
+	// 开启zeroNoStore时传入了0, 值没有写入
+	ErrNotStored = errors.New("fcache: not stored")
+	// 开启了只读模式
+	ErrReadOnly = errors.New("fcache: read only")
+	// Changes传入的序号早于保留的删除记录
+	ErrChangesExpired = errors.New("fcache: changes expired")
+)
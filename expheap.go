@@ -0,0 +1,63 @@
+package fcache
+
+import "container/heap"
+
+type expEntry struct {
+	key string
+	at  int64
+}
+
+// 按过期时间排序的最小堆, key被覆盖或删除后旧的条目留在堆中, 出堆时再丢弃
+type expHeap []expEntry
+
+func (h expHeap) Len() int            { return len(h) }
+func (h expHeap) Less(i, j int) bool  { return h[i].at < h[j].at }
+func (h expHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expHeap) Push(x interface{}) { *h = append(*h, x.(expEntry)) }
+func (h *expHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// 调用时需持有写锁
+func (s *shard) trackExpiration(k string, at int64) {
+	if at <= 0 {
+		return
+	}
+	heap.Push(&s.exp, expEntry{key: k, at: at})
+	// 失效条目过多时按当前条目重建
+	if len(s.exp) > 2*len(s.items)+64 {
+		s.rebuildExpiration()
+	}
+}
+
+func (s *shard) rebuildExpiration() {
+	s.exp = s.exp[:0]
+	for k, v := range s.items {
+		if v.Expiration > 0 {
+			s.exp = append(s.exp, expEntry{key: k, at: v.Expiration})
+		}
+	}
+	heap.Init(&s.exp)
+}
+
+// 弹出所有在now之前过期的条目, 只处理到期的部分而不扫描整个shard
+func (s *shard) popExpired(now int64) map[string]Item {
+	due := map[string]Item{}
+	for len(s.exp) > 0 && s.exp[0].at < now {
+		e := heap.Pop(&s.exp).(expEntry)
+		item, ok := s.items[e.key]
+		if !ok || item.Expiration == 0 || item.pinned {
+			continue
+		}
+		if item.Expiration < now {
+			due[e.key] = item
+		} else if item.Expiration != e.at && s.slides(item) {
+			// 读取时延长了过期时间
+			heap.Push(&s.exp, expEntry{key: e.key, at: item.Expiration})
+		}
+	}
+	return due
+}
@@ -0,0 +1,73 @@
+package fcache
+
+import (
+	"container/heap"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestExpHeapOrder(t *testing.T) {
+	var h expHeap
+	for _, at := range []int64{50, 10, 40, 30, 20, 10} {
+		heap.Push(&h, expEntry{key: "k", at: at})
+	}
+	var got []int64
+	for h.Len() > 0 {
+		got = append(got, heap.Pop(&h).(expEntry).at)
+	}
+	if want := []int64{10, 10, 20, 30, 40, 50}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("pop order %v, want %v", got, want)
+	}
+}
+
+func TestPopExpired(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithShards(1), WithClock(clock), WithGCInterval(0))
+	c.Set("a", 1, 1*time.Second)
+	c.Set("b", 1, 3*time.Second)
+	c.Set("c", 1, 5*time.Second)
+	c.Set("forever", 1, NoExpiration)
+	// 覆盖后堆中的旧位置不再有效
+	c.Set("moved", 1, time.Second)
+	c.Set("moved", 1, time.Hour)
+	c.Set("gone", 1, time.Second)
+	c.Delete("gone")
+
+	tests := []struct {
+		advance time.Duration
+		want    []string
+	}{
+		{500 * time.Millisecond, nil},
+		{time.Second, []string{"a"}},
+		{3 * time.Second, []string{"b"}},
+		{10 * time.Second, []string{"c"}},
+	}
+	s := c.shards[0]
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		s.mu.Lock()
+		due := s.popExpired(c.nowNano())
+		s.unlock()
+		var got []string
+		for k := range due {
+			got = append(got, k)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("after %v: expired %v, want %v", tt.advance, got, tt.want)
+		}
+		for _, k := range got {
+			s.mu.Lock()
+			s.delete(k, Expired)
+			s.unlock()
+		}
+	}
+	if s.exp.Len() != 1 || s.exp[0].key != "moved" {
+		t.Errorf("heap left with %v, want only moved", s.exp)
+	}
+	if n := c.Count(); n != 2 {
+		t.Errorf("Count = %d, want 2", n)
+	}
+}
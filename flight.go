@@ -0,0 +1,256 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 同一个key的并发加载只执行一次
+type flightCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+	// 正在等待结果的调用方数量, 包括发起加载的一方, 由flightGroup.mu保护
+	waiters int
+}
+
+type flightGroup struct {
+	mu        sync.Mutex
+	calls     map[string]*flightCall
+	coalesced uint64
+}
+
+func (g *flightGroup) do(k string, fn func() (interface{}, error)) (interface{}, error) {
+	return g.doCtx(context.Background(), k, fn)
+}
+
+// ctx结束时不再等待并返回ctx的错误, fn在后台继续执行, 结果仍交给其他等待者
+func (g *flightGroup) doCtx(ctx context.Context, k string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if call, ok := g.calls[k]; ok {
+		call.waiters++
+		g.mu.Unlock()
+		atomic.AddUint64(&g.coalesced, 1)
+		defer g.leave(call)
+		return call.wait(ctx)
+	}
+	call := &flightCall{done: make(chan struct{}), waiters: 1}
+	g.calls[k] = call
+	g.mu.Unlock()
+	defer g.leave(call)
+
+	run := func() {
+		defer func() {
+			g.mu.Lock()
+			delete(g.calls, k)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+		// fn panic时等待者拿到这个错误
+		call.err = fmt.Errorf("Loader for item %s panicked", k)
+		call.val, call.err = fn()
+	}
+	if ctx.Done() == nil {
+		run()
+		return call.val, call.err
+	}
+	go func() {
+		// 后台执行时panic已由compute记录并转为错误交给等待者
+		defer func() { recover() }()
+		run()
+	}()
+	return call.wait(ctx)
+}
+
+func (g *flightGroup) leave(call *flightCall) {
+	g.mu.Lock()
+	call.waiters--
+	g.mu.Unlock()
+}
+
+// 返回正在加载的key及等待的调用方数量
+func (g *flightGroup) inFlight() map[string]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	m := make(map[string]int, len(g.calls))
+	for k, call := range g.calls {
+		m[k] = call.waiters
+	}
+	return m
+}
+
+// 返回正在执行的加载及每个key上等待结果的调用方数量, 包括发起加载的一方
+// 调用方因ctx结束不再等待后不计入, 加载本身可能仍在后台执行
+func (c *Cache) InFlight() map[string]int {
+	return c.flight.inFlight()
+}
+
+func (call *flightCall) wait(ctx context.Context) (interface{}, error) {
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// key不存在时调用loader加载并以ttl写入, 并发未命中时loader只执行一次
+// loader返回错误时不写入; 设置了negativeTTL时错误会被缓存, 期间直接返回该错误
+// 加载成功但写入失败时(如RejectOnFull下达到上限)返回加载的值和写入的错误; 设置了WithLoader时也只调用传入的loader
+func (c *Cache) GetOrCompute(k string, ttl time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+	return c.GetOrComputeCtx(context.Background(), k, ttl, func(context.Context) (interface{}, error) {
+		return loader()
+	})
+}
+
+// 与GetOrCompute相同, ctx结束时不再等待加载, 加载仍在后台完成并写入
+// loader拿到的ctx保留ctx中的值, 但不会随ctx取消
+func (c *Cache) GetOrComputeCtx(ctx context.Context, k string, ttl time.Duration, loader func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	v, ok := c.get(k)
+	early := ok && c.expiresEarly(k)
+	c.recordGet(ok && !early)
+	if ok && !early {
+		return v, nil
+	}
+	nv, err := c.compute(ctx, k, early, func(ctx context.Context) (interface{}, time.Duration, error) {
+		v, err := loader(ctx)
+		return v, ttl, err
+	})
+	// 提前加载失败时原值仍未过期
+	if err != nil && early {
+		return v, nil
+	}
+	return nv, err
+}
+
+// 并发未命中时loader只执行一次; 返回false表示key一定不存在而跳过了加载
+// early表示k存在但按提前过期重新加载
+func (c *Cache) readThrough(ctx context.Context, k string, early bool) (interface{}, bool, error) {
+	if !c.MayContain(k) {
+		return nil, false, nil
+	}
+	loader := c.conf().loader
+	v, err := c.compute(ctx, k, early, func(ctx context.Context) (interface{}, time.Duration, error) {
+		return loader(ctx, k)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// 同一个key同时只有一个后台刷新, 刷新失败时保留原值直到过期
+func (c *Cache) refreshAhead(k string, window time.Duration) {
+	s := c.shard(k)
+	s.mu.RLock()
+	e := s.items[k].Expiration
+	s.mu.RUnlock()
+	if e == 0 || c.remaining(e) > window {
+		return
+	}
+	if _, busy := c.refreshing.LoadOrStore(k, struct{}{}); busy {
+		return
+	}
+	loader := c.conf().loader
+	go func() {
+		defer c.refreshing.Delete(k)
+		defer func() {
+			if x := recover(); x != nil {
+				c.loaderPanicked(k, x)
+			}
+		}()
+		start := time.Now()
+		ctx, span := c.startSpan(context.Background(), "fcache.refresh", attribute.String("fcache.key", k))
+		v, d, err := loader(ctx, k)
+		endSpan(span, err)
+		took := c.recordLoad(start, err)
+		if err != nil {
+			c.warn("fcache: refresh failed", "key", k, "err", err)
+			return
+		}
+		c.setLoaded(context.Background(), k, v, d, took)
+	}()
+}
+
+// early为true时即使k存在也重新加载
+func (c *Cache) compute(ctx context.Context, k string, early bool, fn func(ctx context.Context) (interface{}, time.Duration, error)) (interface{}, error) {
+	if err := c.negative.get(k, c.nowNano()); err != nil {
+		return nil, err
+	}
+	// 加载结果由所有等待者共享, 不随某一个调用者取消
+	lctx := context.WithoutCancel(ctx)
+	return c.flight.doCtx(ctx, k, func() (interface{}, error) {
+		// 记录后继续panic, 同步调用时传给调用方, 后台执行时由doCtx转为错误
+		defer func() {
+			if x := recover(); x != nil {
+				c.loaderPanicked(k, x)
+				panic(x)
+			}
+		}()
+		// 可能刚被上一次加载写入
+		if v, ok := c.get(k); ok && !early {
+			return v, nil
+		}
+		start := time.Now()
+		sctx, span := c.startSpan(lctx, "fcache.load", attribute.String("fcache.key", k))
+		v, d, err := fn(sctx)
+		endSpan(span, err)
+		took := c.recordLoad(start, err)
+		if err != nil {
+			c.warn("fcache: loader failed", "key", k, "err", err)
+			if ttl := c.conf().negativeTTL; ttl > 0 {
+				c.negative.set(k, err, c.now().Add(ttl).UnixNano())
+			}
+			return nil, err
+		}
+		c.negative.delete(k)
+		// 写入失败时仍返回加载到的值
+		if err := c.setLoaded(lctx, k, v, d, took); err != nil && !errors.Is(err, ErrReadOnly) {
+			return v, err
+		}
+		return v, nil
+	})
+}
+
+func (c *Cache) loaderPanicked(k string, x interface{}) {
+	atomic.AddUint64(&c.stats.loadPanics, 1)
+	c.warn("fcache: loader panicked", "key", k, "panic", x)
+}
+
+// key存在时返回当前值和true, 否则写入v并返回v和false
+func (c *Cache) GetOrSet(k string, v interface{}, d time.Duration) (interface{}, bool) {
+	if c.checkTTL(d) != nil || c.skipStore(d) {
+		if old, ok := c.Get(k); ok {
+			return old, true
+		}
+		return v, false
+	}
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	defer c.shrink()
+	defer s.unlock()
+	if old, ok := s.get(k); ok {
+		return old, true
+	}
+	if err := s.waitSpace(context.Background(), k); err != nil {
+		return v, false
+	}
+	if old, ok := s.get(k); ok {
+		return old, true
+	}
+	s.set(k, v, d)
+	return v, false
+}
@@ -0,0 +1,142 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrComputeSingleFlight(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    interface{}
+		stored  bool
+		callers int
+	}{
+		{"value", nil, "v", true, 32},
+		{"error", errors.New("backend down"), nil, false, 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(WithGCInterval(0))
+			var calls int32
+			release := make(chan struct{})
+			var wg sync.WaitGroup
+			results := make([]interface{}, tt.callers)
+			errs := make([]error, tt.callers)
+			for i := 0; i < tt.callers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i], errs[i] = c.GetOrCompute("k", time.Hour, func() (interface{}, error) {
+						atomic.AddInt32(&calls, 1)
+						<-release
+						if tt.err != nil {
+							return nil, tt.err
+						}
+						return tt.want, nil
+					})
+				}(i)
+			}
+			// 等所有调用者都在等待同一次加载
+			for deadline := time.Now().Add(time.Second); c.InFlight()["k"] < tt.callers && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			close(release)
+			wg.Wait()
+			if n := atomic.LoadInt32(&calls); n != 1 {
+				t.Fatalf("loader ran %d times, want 1", n)
+			}
+			for i := range results {
+				if results[i] != tt.want || !errors.Is(errs[i], tt.err) {
+					t.Fatalf("caller %d got %v, %v", i, results[i], errs[i])
+				}
+			}
+			if _, ok := c.Get("k"); ok != tt.stored {
+				t.Fatalf("stored = %v, want %v", ok, tt.stored)
+			}
+		})
+	}
+}
+
+type ctxKey struct{}
+
+// 注册了WithLoader时GetOrComputeCtx仍只调用传入的loader, 并把ctx中的值传给它
+func TestGetOrComputeCtxUsesExplicitLoader(t *testing.T) {
+	registered := 0
+	c := New(WithGCInterval(0), WithLoader(func(ctx context.Context, k string) (interface{}, time.Duration, error) {
+		registered++
+		return "from-registered", time.Hour, nil
+	}))
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request-1")
+	v, err := c.GetOrComputeCtx(ctx, "k", time.Hour, func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(ctxKey{}), nil
+	})
+	if err != nil || v != "request-1" || registered != 0 {
+		t.Fatalf("got %v, %v; registered loader called %d times", v, err, registered)
+	}
+	if v, ok := c.Get("k"); !ok || v != "request-1" {
+		t.Fatalf("Get = %v, %v", v, ok)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetOrComputeCtx(canceled, "other", time.Hour, func(context.Context) (interface{}, error) {
+		t.Error("loader called with a canceled ctx")
+		return nil, nil
+	}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err %v, want Canceled", err)
+	}
+}
+
+type warnLogger struct {
+	mu    sync.Mutex
+	warns []string
+}
+
+func (l *warnLogger) Debug(msg string, args ...interface{}) {}
+
+func (l *warnLogger) Warn(msg string, args ...interface{}) {
+	l.mu.Lock()
+	l.warns = append(l.warns, msg)
+	l.mu.Unlock()
+}
+
+// 后台执行的loader panic时返回错误, 并记录日志和计数
+func TestGetOrComputeLoaderPanic(t *testing.T) {
+	l := &warnLogger{}
+	c := New(WithGCInterval(0), WithLogger(l))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := c.GetOrComputeCtx(ctx, "k", time.Hour, func(context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	if err == nil {
+		t.Fatal("panic not reported as an error")
+	}
+	if n := c.Stats().LoadPanics; n != 1 {
+		t.Fatalf("LoadPanics = %d, want 1", n)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.warns) != 1 {
+		t.Fatalf("warnings = %v", l.warns)
+	}
+}
+
+// RejectOnFull下写入失败时仍返回加载的值
+func TestGetOrComputeRejectOnFull(t *testing.T) {
+	c := New(WithGCInterval(0), WithShards(1), WithMaxEntries(1), WithOverflowPolicy(RejectOnFull))
+	c.Set("a", 1, NoExpiration)
+	v, err := c.GetOrCompute("b", time.Hour, func() (interface{}, error) { return 2, nil })
+	if v != 2 || !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("got %v, %v; want 2, ErrCacheFull", v, err)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("rejected value stored")
+	}
+}
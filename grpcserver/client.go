@@ -0,0 +1,47 @@
+package grpcserver
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Cache服务的客户端, 使用本包的消息类型, 不需要protoc生成的代码
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+// cc由调用方创建和关闭
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req, resp message) error {
+	return c.cc.Invoke(ctx, "/fcache.Cache/"+method, req, resp, grpc.ForceCodec(codec{}))
+}
+
+// key不存在时返回false和nil错误
+func (c *Client) Get(ctx context.Context, k string) ([]byte, bool, error) {
+	resp := &GetResponse{}
+	if err := c.invoke(ctx, "Get", &GetRequest{Key: k}, resp); err != nil {
+		return nil, false, err
+	}
+	return resp.Value, resp.Found, nil
+}
+
+// d小于等于0表示永不过期, 服务端的默认过期时间无法通过gRPC指定
+func (c *Client) Set(ctx context.Context, k string, v []byte, d time.Duration) error {
+	req := &SetRequest{Key: k, Value: v}
+	if d > 0 {
+		req.TtlMs = d.Milliseconds()
+		if req.TtlMs == 0 {
+			req.TtlMs = 1
+		}
+	}
+	return c.invoke(ctx, "Set", req, &SetResponse{})
+}
+
+func (c *Client) Delete(ctx context.Context, k string) error {
+	return c.invoke(ctx, "Delete", &DeleteRequest{Key: k}, &DeleteResponse{})
+}
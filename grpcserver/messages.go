@@ -0,0 +1,347 @@
+package grpcserver
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fcache.proto中的消息, 按protobuf wire格式手工编解码, 不依赖protoc生成的代码
+type message interface {
+	marshal(b []byte) []byte
+	unmarshal(b []byte) error
+}
+
+type GetRequest struct {
+	Key string
+}
+
+type GetResponse struct {
+	Found bool
+	Value []byte
+	TtlMs int64
+}
+
+type SetRequest struct {
+	Key   string
+	Value []byte
+	TtlMs int64
+}
+
+type SetResponse struct{}
+
+type DeleteRequest struct {
+	Key string
+}
+
+type DeleteResponse struct{}
+
+type Op_Type int32
+
+const (
+	Op_GET Op_Type = iota
+	Op_SET
+	Op_DELETE
+)
+
+type Op struct {
+	Type  Op_Type
+	Key   string
+	Value []byte
+	TtlMs int64
+}
+
+type BatchRequest struct {
+	Ops []*Op
+}
+
+type Result struct {
+	Found bool
+	Value []byte
+}
+
+type BatchResponse struct {
+	Results []*Result
+}
+
+type WatchRequest struct {
+	Pattern string
+}
+
+type Event_Type int32
+
+const (
+	Event_SET Event_Type = iota
+	Event_DELETE
+	Event_EXPIRE
+)
+
+type Event struct {
+	Type   Event_Type
+	Key    string
+	Value  []byte
+	Reason string
+}
+
+// proto3中零值字段不编码
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	return appendVarint(b, num, protowire.EncodeBool(v))
+}
+
+func appendMessage(b []byte, num protowire.Number, m message) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.marshal(nil))
+}
+
+// 逐个字段解析, varint字段通过x传入, 长度分隔的字段通过v传入, 其余类型跳过
+func parse(b []byte, field func(num protowire.Number, x uint64, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var err error
+		switch typ {
+		case protowire.VarintType:
+			var x uint64
+			x, n = protowire.ConsumeVarint(b)
+			if n >= 0 {
+				err = field(num, x, nil)
+			}
+		case protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			if n >= 0 {
+				err = field(num, 0, v)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// 解析出的[]byte引用输入, 需要复制
+func clone(v []byte) []byte {
+	return append([]byte(nil), v...)
+}
+
+func (m *GetRequest) marshal(b []byte) []byte {
+	return appendString(b, 1, m.Key)
+}
+
+func (m *GetRequest) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		if num == 1 {
+			m.Key = string(v)
+		}
+		return nil
+	})
+}
+
+func (m *GetResponse) marshal(b []byte) []byte {
+	b = appendBool(b, 1, m.Found)
+	b = appendBytes(b, 2, m.Value)
+	return appendVarint(b, 3, uint64(m.TtlMs))
+}
+
+func (m *GetResponse) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		switch num {
+		case 1:
+			m.Found = x != 0
+		case 2:
+			m.Value = clone(v)
+		case 3:
+			m.TtlMs = int64(x)
+		}
+		return nil
+	})
+}
+
+func (m *SetRequest) marshal(b []byte) []byte {
+	b = appendString(b, 1, m.Key)
+	b = appendBytes(b, 2, m.Value)
+	return appendVarint(b, 3, uint64(m.TtlMs))
+}
+
+func (m *SetRequest) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		switch num {
+		case 1:
+			m.Key = string(v)
+		case 2:
+			m.Value = clone(v)
+		case 3:
+			m.TtlMs = int64(x)
+		}
+		return nil
+	})
+}
+
+func (m *SetResponse) marshal(b []byte) []byte { return b }
+
+func (m *SetResponse) unmarshal(b []byte) error {
+	return parse(b, func(protowire.Number, uint64, []byte) error { return nil })
+}
+
+func (m *DeleteRequest) marshal(b []byte) []byte {
+	return appendString(b, 1, m.Key)
+}
+
+func (m *DeleteRequest) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		if num == 1 {
+			m.Key = string(v)
+		}
+		return nil
+	})
+}
+
+func (m *DeleteResponse) marshal(b []byte) []byte { return b }
+
+func (m *DeleteResponse) unmarshal(b []byte) error {
+	return parse(b, func(protowire.Number, uint64, []byte) error { return nil })
+}
+
+func (m *Op) marshal(b []byte) []byte {
+	b = appendVarint(b, 1, uint64(m.Type))
+	b = appendString(b, 2, m.Key)
+	b = appendBytes(b, 3, m.Value)
+	return appendVarint(b, 4, uint64(m.TtlMs))
+}
+
+func (m *Op) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		switch num {
+		case 1:
+			m.Type = Op_Type(x)
+		case 2:
+			m.Key = string(v)
+		case 3:
+			m.Value = clone(v)
+		case 4:
+			m.TtlMs = int64(x)
+		}
+		return nil
+	})
+}
+
+func (m *BatchRequest) marshal(b []byte) []byte {
+	for _, op := range m.Ops {
+		b = appendMessage(b, 1, op)
+	}
+	return b
+}
+
+func (m *BatchRequest) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		if num != 1 {
+			return nil
+		}
+		op := &Op{}
+		m.Ops = append(m.Ops, op)
+		return op.unmarshal(v)
+	})
+}
+
+func (m *Result) marshal(b []byte) []byte {
+	b = appendBool(b, 1, m.Found)
+	return appendBytes(b, 2, m.Value)
+}
+
+func (m *Result) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		switch num {
+		case 1:
+			m.Found = x != 0
+		case 2:
+			m.Value = clone(v)
+		}
+		return nil
+	})
+}
+
+func (m *BatchResponse) marshal(b []byte) []byte {
+	for _, r := range m.Results {
+		b = appendMessage(b, 1, r)
+	}
+	return b
+}
+
+func (m *BatchResponse) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		if num != 1 {
+			return nil
+		}
+		r := &Result{}
+		m.Results = append(m.Results, r)
+		return r.unmarshal(v)
+	})
+}
+
+func (m *WatchRequest) marshal(b []byte) []byte {
+	return appendString(b, 1, m.Pattern)
+}
+
+func (m *WatchRequest) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		if num == 1 {
+			m.Pattern = string(v)
+		}
+		return nil
+	})
+}
+
+func (m *Event) marshal(b []byte) []byte {
+	b = appendVarint(b, 1, uint64(m.Type))
+	b = appendString(b, 2, m.Key)
+	b = appendBytes(b, 3, m.Value)
+	return appendString(b, 4, m.Reason)
+}
+
+func (m *Event) unmarshal(b []byte) error {
+	return parse(b, func(num protowire.Number, x uint64, v []byte) error {
+		switch num {
+		case 1:
+			m.Type = Event_Type(x)
+		case 2:
+			m.Key = string(v)
+		case 3:
+			m.Value = clone(v)
+		case 4:
+			m.Reason = string(v)
+		}
+		return nil
+	})
+}
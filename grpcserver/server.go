@@ -0,0 +1,275 @@
+// Cache的gRPC服务, 接口定义见fcache.proto
+// 多个服务可以通过同一个进程内的Cache共享缓存
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fredalxin/fcache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+type Server struct {
+	cache *fcache.Cache
+}
+
+func New(c *fcache.Cache) *Server {
+	return &Server{cache: c}
+}
+
+// 创建只提供Cache服务的grpc.Server
+func NewGRPCServer(c *fcache.Cache, opts ...grpc.ServerOption) *grpc.Server {
+	g := grpc.NewServer(append([]grpc.ServerOption{ServerCodec()}, opts...)...)
+	New(c).Register(g)
+	return g
+}
+
+// 注册到已有的grpc.Server; 本包的Client不需要额外设置, protoc生成的客户端需要创建时传入ServerCodec
+func (s *Server) Register(g *grpc.Server) {
+	g.RegisterService(&serviceDesc, s)
+}
+
+// 以自己的名字注册, 不替换全局的proto codec
+const codecName = "fcache-proto"
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// 所有请求都用本包的codec解码, 用于接收protoc生成的客户端以proto发送的请求
+func ServerCodec() grpc.ServerOption {
+	return grpc.ForceServerCodec(codec{})
+}
+
+// 本包的消息不是protoc生成的类型, 需要用这个codec编解码, 编码与proto相同; 其他消息交给默认的proto codec
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(message); ok {
+		return m.marshal(nil), nil
+	}
+	if p := encoding.GetCodec("proto"); p != nil {
+		return p.Marshal(v)
+	}
+	return nil, fmt.Errorf("grpcserver: cannot marshal %T", v)
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(message); ok {
+		return m.unmarshal(data)
+	}
+	if p := encoding.GetCodec("proto"); p != nil {
+		return p.Unmarshal(data, v)
+	}
+	return fmt.Errorf("grpcserver: cannot unmarshal into %T", v)
+}
+
+func (codec) Name() string {
+	return codecName
+}
+
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty key")
+	}
+	v, exp, ok := s.cache.GetWithExpiration(req.Key)
+	if !ok {
+		return &GetResponse{}, nil
+	}
+	b, err := encodeValue(v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &GetResponse{Found: true, Value: b}
+	if !exp.IsZero() {
+		resp.TtlMs = time.Until(exp).Milliseconds()
+	}
+	return resp, nil
+}
+
+func (s *Server) Set(ctx context.Context, req *SetRequest) (*SetResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty key")
+	}
+	if err := s.cache.SetCtx(ctx, req.Key, req.Value, ttl(req.TtlMs)); err != nil {
+		return nil, toStatus(err)
+	}
+	return &SetResponse{}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty key")
+	}
+	s.cache.Delete(req.Key)
+	return &DeleteResponse{}, nil
+}
+
+// 不是事务, 出错时已执行的操作不会回滚
+func (s *Server) Batch(ctx context.Context, req *BatchRequest) (*BatchResponse, error) {
+	resp := &BatchResponse{Results: make([]*Result, 0, len(req.Ops))}
+	for i, op := range req.Ops {
+		if op.Key == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "op %d: empty key", i)
+		}
+		r := &Result{}
+		switch op.Type {
+		case Op_GET:
+			v, ok := s.cache.Get(op.Key)
+			if ok {
+				b, err := encodeValue(v)
+				if err != nil {
+					return nil, status.Errorf(codes.Internal, "op %d: %v", i, err)
+				}
+				r.Found, r.Value = true, b
+			}
+		case Op_SET:
+			if err := s.cache.SetCtx(ctx, op.Key, op.Value, ttl(op.TtlMs)); err != nil {
+				return nil, toStatus(err)
+			}
+		case Op_DELETE:
+			s.cache.Delete(op.Key)
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "op %d: unknown type %d", i, op.Type)
+		}
+		resp.Results = append(resp.Results, r)
+	}
+	return resp, nil
+}
+
+// 订阅者消费过慢时事件会被丢弃, 见fcache.Subscribe
+func (s *Server) Watch(req *WatchRequest, stream grpc.ServerStream) error {
+	events, cancel, err := s.cache.Subscribe(req.Pattern)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	defer cancel()
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "cache closed")
+			}
+			ev := &Event{Key: e.Key}
+			switch e.Type {
+			case fcache.EventSet:
+				ev.Type = Event_SET
+				if ev.Value, err = encodeValue(e.Value); err != nil {
+					return status.Error(codes.Internal, err.Error())
+				}
+			case fcache.EventDelete:
+				ev.Type, ev.Reason = Event_DELETE, e.Reason.String()
+			case fcache.EventExpire:
+				ev.Type, ev.Reason = Event_EXPIRE, e.Reason.String()
+			}
+			if err := stream.SendMsg(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// 小于等于0表示永不过期
+func ttl(ms int64) time.Duration {
+	if ms <= 0 {
+		return fcache.NoExpiration
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// []byte和string原样返回, 其他类型编码为JSON
+func encodeValue(v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case string:
+		return []byte(b), nil
+	}
+	return json.Marshal(v)
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, fcache.ErrClosed):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, fcache.ErrCacheFull):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, fcache.ErrAmbiguousTTL):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// RegisterService要求HandlerType为接口指针, 用于检查注册的实现
+type cacheServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Batch(context.Context, *BatchRequest) (*BatchResponse, error)
+	Watch(*WatchRequest, grpc.ServerStream) error
+}
+
+// 与protoc-gen-go-grpc生成的服务描述一致
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "fcache.Cache",
+	HandlerType: (*cacheServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Get", Handler: unary("Get", func(s *Server, ctx context.Context, req *GetRequest) (interface{}, error) {
+			return s.Get(ctx, req)
+		})},
+		{MethodName: "Set", Handler: unary("Set", func(s *Server, ctx context.Context, req *SetRequest) (interface{}, error) {
+			return s.Set(ctx, req)
+		})},
+		{MethodName: "Delete", Handler: unary("Delete", func(s *Server, ctx context.Context, req *DeleteRequest) (interface{}, error) {
+			return s.Delete(ctx, req)
+		})},
+		{MethodName: "Batch", Handler: unary("Batch", func(s *Server, ctx context.Context, req *BatchRequest) (interface{}, error) {
+			return s.Batch(ctx, req)
+		})},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Watch",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := &WatchRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*Server).Watch(req, stream)
+			},
+			ServerStreams: true,
+		},
+	},
+	Metadata: "fcache.proto",
+}
+
+func unary[T any, PT interface {
+	*T
+	message
+}](method string, call func(s *Server, ctx context.Context, req PT) (interface{}, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := PT(new(T))
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(*Server), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/fcache.Cache/" + method}
+		return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(*Server), ctx, req.(PT))
+		})
+	}
+}
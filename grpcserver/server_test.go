@@ -0,0 +1,59 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fredalxin/fcache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dial(t *testing.T, g *grpc.Server) *Client {
+	lis := bufconn.Listen(1 << 20)
+	go g.Serve(lis)
+	t.Cleanup(g.Stop)
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return NewClient(cc)
+}
+
+// 有无ServerCodec时Client都能调用, 全局的proto codec不被替换
+func TestClientServer(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func(c *fcache.Cache) *grpc.Server
+	}{
+		{"NewGRPCServer", func(c *fcache.Cache) *grpc.Server { return NewGRPCServer(c) }},
+		{"Register", func(c *fcache.Cache) *grpc.Server {
+			g := grpc.NewServer()
+			New(c).Register(g)
+			return g
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dial(t, tt.new(fcache.New(fcache.WithGCInterval(0))))
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := client.Set(ctx, "k", []byte("v"), time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			if v, ok, err := client.Get(ctx, "k"); err != nil || !ok || string(v) != "v" {
+				t.Fatalf("Get = %q, %v, %v", v, ok, err)
+			}
+		})
+	}
+	if _, ok := encoding.GetCodec("proto").(codec); ok {
+		t.Fatal("proto codec replaced")
+	}
+}
@@ -0,0 +1,198 @@
+// 以HTTP接口暴露Cache, 供非Go进程作为sidecar缓存使用
+//
+//	GET    /cache/{key}  读取, 未命中返回404
+//	PUT    /cache/{key}  写入请求体, 存活时间取X-Cache-TTL头或ttl参数
+//	DELETE /cache/{key}  删除
+//	GET    /stats        统计数据
+//	GET    /keys         列出key, 可用pattern参数按glob过滤
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fredalxin/fcache"
+)
+
+const (
+	prefix    = "/cache/"
+	ttlHeader = "X-Cache-TTL"
+	// 请求体的大小上限
+	maxBody = 32 << 20
+)
+
+type Server struct {
+	cache *fcache.Cache
+}
+
+func New(c *fcache.Cache) *Server {
+	return &Server{cache: c}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, prefix):
+		s.serveKey(w, r, strings.TrimPrefix(r.URL.Path, prefix))
+	case r.URL.Path == "/stats":
+		s.serveStats(w, r)
+	case r.URL.Path == "/keys":
+		s.serveKeys(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveKey(w http.ResponseWriter, r *http.Request, k string) {
+	if k == "" {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		v, exp, ok := s.cache.GetWithExpiration(k)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if !exp.IsZero() {
+			w.Header().Set(ttlHeader, strconv.FormatInt(int64(time.Until(exp)/time.Second), 10))
+		}
+		writeValue(w, v)
+	case http.MethodPut, http.MethodPost:
+		ttl, err := parseTTL(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxBody {
+			http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := s.cache.SetCtx(r.Context(), k, body, ttl); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		s.cache.Delete(k)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// 整数表示秒, 否则按time.ParseDuration解析; 未指定时使用默认过期时间, 0或负数表示永不过期
+func parseTTL(r *http.Request) (time.Duration, error) {
+	v := r.Header.Get(ttlHeader)
+	if v == "" {
+		v = r.URL.Query().Get("ttl")
+	}
+	if v == "" {
+		return fcache.DefaultExpiration, nil
+	}
+	var d time.Duration
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		d = time.Duration(n) * time.Second
+	} else if d, err = time.ParseDuration(v); err != nil {
+		return 0, fmt.Errorf("bad ttl %q", v)
+	}
+	if d <= 0 {
+		return fcache.NoExpiration, nil
+	}
+	return d, nil
+}
+
+// []byte和string原样返回, 其他类型编码为JSON
+func writeValue(w http.ResponseWriter, v interface{}) {
+	switch b := v.(type) {
+	case []byte:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(b)
+	case string:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, b)
+	default:
+		writeJSON(w, v)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) serveStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	st := s.cache.Stats()
+	writeJSON(w, map[string]interface{}{
+		"hits":         st.Hits,
+		"misses":       st.Misses,
+		"hit_ratio":    st.HitRatio(),
+		"sets":         st.Sets,
+		"deletes":      st.Deletes,
+		"evictions":    st.Evictions,
+		"expired":      st.Expired,
+		"gc_runs":      st.GCRuns,
+		"gc_seconds":   st.GCTime.Seconds(),
+		"entries":      s.cache.Count(),
+		"memory_bytes": s.cache.MemoryUsage(),
+	})
+}
+
+// 调试用的处理器, 以JSON返回最大和最热的n个key, n由参数n指定, 默认20; 会开启c的内存统计
+func DebugHandler(c *fcache.Cache) http.Handler {
+	c.SetMemoryTracking(true)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 20
+		if v := r.URL.Query().Get("n"); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil || n < 0 {
+				http.Error(w, "bad n", http.StatusBadRequest)
+				return
+			}
+		}
+		writeJSON(w, map[string]interface{}{
+			"entries":      c.Count(),
+			"memory_bytes": c.MemoryUsage(),
+			"largest":      c.LargestKeys(n),
+			"hottest":      c.HottestKeys(n),
+		})
+	})
+}
+
+func (s *Server) serveKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		writeJSON(w, s.cache.Keys())
+		return
+	}
+	keys, err := s.cache.KeysByPattern(pattern)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	writeJSON(w, keys)
+}
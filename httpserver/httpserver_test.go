@@ -0,0 +1,28 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fredalxin/fcache"
+)
+
+// 没有内存上限时DebugHandler也返回条目大小
+func TestDebugHandlerSizes(t *testing.T) {
+	c := fcache.New(fcache.WithGCInterval(0))
+	c.Set("k", []byte("value"), fcache.NoExpiration)
+	h := DebugHandler(c)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug?n=5", nil))
+	var got struct {
+		Memory  int64             `json:"memory_bytes"`
+		Largest []json.RawMessage `json:"largest"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	if got.Memory <= 0 || len(got.Largest) != 1 {
+		t.Fatalf("got %s", w.Body.String())
+	}
+}
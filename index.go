@@ -0,0 +1,105 @@
+package fcache
+
+import "sync/atomic"
+
+// 从值中提取索引值, 同一个值需要始终返回相同的结果
+type IndexFunc func(v interface{}) []string
+
+// 注册名为name的二级索引, 写入时以f提取的每个索引值关联到key, 之后可通过KeysByIndex查找或DeleteByIndex批量删除
+func WithIndex(name string, f IndexFunc) Option {
+	return func(o *options) {
+		if o.indexes == nil {
+			o.indexes = map[string]IndexFunc{}
+		}
+		o.indexes[name] = f
+	}
+}
+
+// 返回索引name中值为value的未过期的key, 索引未注册时返回nil
+func (c *Cache) KeysByIndex(name, value string) []string {
+	var keys []string
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for k := range s.indexes[name][value] {
+			if item := s.items[k]; !c.expired(item) && !s.pending(k) {
+				keys = append(keys, k)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return keys
+}
+
+// 删除索引name中值为value的所有key, 返回删除的数量
+func (c *Cache) DeleteByIndex(name, value string) int {
+	if c.writable() != nil {
+		return 0
+	}
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for k := range s.indexes[name][value] {
+			if s.delete(k, Deleted) {
+				n++
+			}
+		}
+		s.unlock()
+	}
+	atomic.AddUint64(&c.stats.deletes, uint64(n))
+	return n
+}
+
+// 按注册的索引提取v的索引值, 没有注册索引时返回nil
+func (c *Cache) indexValues(v interface{}) map[string][]string {
+	if len(c.indexes) == 0 {
+		return nil
+	}
+	values := make(map[string][]string, len(c.indexes))
+	for name, f := range c.indexes {
+		if vals := f(v); len(vals) > 0 {
+			values[name] = vals
+		}
+	}
+	return values
+}
+
+// 调用时需持有写锁
+func (s *shard) index(k string, values map[string][]string) {
+	if len(values) == 0 {
+		return
+	}
+	if s.indexes == nil {
+		s.indexes = map[string]map[string]map[string]struct{}{}
+	}
+	for name, vals := range values {
+		idx, ok := s.indexes[name]
+		if !ok {
+			idx = map[string]map[string]struct{}{}
+			s.indexes[name] = idx
+		}
+		for _, v := range vals {
+			keys, ok := idx[v]
+			if !ok {
+				keys = map[string]struct{}{}
+				idx[v] = keys
+			}
+			keys[k] = struct{}{}
+		}
+	}
+}
+
+func (s *shard) unindex(k string, values map[string][]string) {
+	for name, vals := range values {
+		idx := s.indexes[name]
+		for _, v := range vals {
+			keys := idx[v]
+			delete(keys, k)
+			if len(keys) == 0 {
+				delete(idx, v)
+			}
+		}
+		if len(idx) == 0 {
+			delete(s.indexes, name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package fcache
+
+import "time"
+
+// 条目的元数据, 读取次数和最后读取时间从最近一次写入开始统计
+type ItemInfo struct {
+	Created    time.Time
+	LastAccess time.Time
+	// 没有过期时间时为零值
+	Expiration time.Time
+	TTL        time.Duration
+	Hits       uint64
+	Version    uint64
+	// 估算的内存占用, 没有内存上限也没有开启内存统计时为0
+	Size int64
+	Tags []string
+}
+
+// 不计入读取次数和命中统计, 也不影响淘汰顺序
+func (c *Cache) ItemInfo(k string) (ItemInfo, bool) {
+	s := c.shard(k)
+	s.mu.RLock()
+	item, ok := s.items[k]
+	pending := s.pending(k)
+	s.mu.RUnlock()
+	if !ok || pending || c.expired(item) {
+		return ItemInfo{}, false
+	}
+	info := ItemInfo{
+		Created: time.Unix(0, item.Created),
+		TTL:     item.TTL,
+		Hits:    item.hits,
+		Version: item.Version,
+		Size:    item.size,
+		Tags:    item.Tags,
+	}
+	if item.accessed > 0 {
+		info.LastAccess = time.Unix(0, item.accessed)
+	}
+	if item.Expiration > 0 {
+		info.Expiration = time.Unix(0, item.Expiration)
+	}
+	return info, true
+}
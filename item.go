@@ -3,13 +3,56 @@ package fcache
 import "time"
 
 type Item struct {
-	Object interface{}
+	Object     interface{}
 	Expiration int64
+	// 写入时间
+	Created int64
+	// 写入时的存活时间, 0表示永不过期
+	TTL time.Duration
+	// 每次修改值时从缓存范围内单调递增的计数器取得, 删除后重新写入的key也不会得到用过的版本号
+	Version uint64
+	// 写入时附加的标签, 用于DeleteByTag
+	Tags []string
+	// SetWithDeps声明的依赖的key
+	Deps []string
+	// 每次读取时按TTL延长过期时间
+	Sliding bool
+	// 存活的最晚时间, 滑动过期, Touch和Persist都不能超过, 0表示不限制
+	Deadline int64
+	// SetWithCost指定的开销, 计入WithMaxCost的预算, 0按1计算
+	Cost int64
+	// SetSoft写入, 内存紧张时优先移除
+	Soft bool
+	// 估算的内存占用, 不参与序列化
+	size int64
+	// 写入时提取的各索引的索引值, 不参与序列化
+	indexed map[string][]string
+	// 最后一次读取的时间和读取次数, 重新写入时清零, 不参与序列化
+	accessed int64
+	hits     uint64
+	// Pin固定的条目不会过期或被淘汰, 不参与序列化
+	pinned bool
+	// 由loader加载时的耗时, 用于提前过期, 不参与序列化
+	loadTime int64
+	// 最后一次写入时的写入序号, 开启WithChangeLog时用于增量导出, 不参与序列化
+	seq uint64
 }
 
-func (item Item) Expired() bool{
-	if item.Expiration == 0{
+// 按系统时间判断, 设置了WithClock时使用ExpiredAt(c.Now())
+func (item Item) Expired() bool {
+	return item.ExpiredAt(time.Now())
+}
+
+// 在now时是否已过期
+func (item Item) ExpiredAt(now time.Time) bool {
+	if item.Expiration == 0 || item.pinned {
 		return false
 	}
-	return time.Now().UnixNano() > item.Expiration
+	return now.UnixNano() > item.Expiration
+}
+
+// key及其对应的条目
+type Entry struct {
+	Key  string
+	Item Item
 }
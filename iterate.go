@@ -0,0 +1,50 @@
+package fcache
+
+// 复制shard中未过期的条目, 只持有读锁
+func (s *shard) snapshot() []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := make([]Entry, 0, len(s.items))
+	for k, v := range s.items {
+		if s.c.expired(v) || s.pending(k) {
+			continue
+		}
+		entries = append(entries, Entry{Key: k, Item: v})
+	}
+	return entries
+}
+
+// 遍历未过期的条目, f返回false时停止
+// 逐个shard复制后在锁外调用f, f中可以读写cache, 但看到的不是同一时刻的快照
+func (c *Cache) Range(f func(k string, v interface{}) bool) {
+	for _, s := range c.shards {
+		for _, e := range s.snapshot() {
+			if !f(e.Key, c.copyValue(unpack(e.Item.Object))) {
+				return
+			}
+		}
+	}
+}
+
+func (c *Cache) Keys() []string {
+	keys := make([]string, 0, c.Count())
+	for _, s := range c.shards {
+		for _, e := range s.snapshot() {
+			keys = append(keys, e.Key)
+		}
+	}
+	return keys
+}
+
+// 返回未过期条目的副本
+func (c *Cache) Items() map[string]Item {
+	items := make(map[string]Item, c.Count())
+	for _, s := range c.shards {
+		for _, e := range s.snapshot() {
+			item := e.Item.unpacked()
+			item.Object = c.copyValue(item.Object)
+			items[e.Key] = item
+		}
+	}
+	return items
+}
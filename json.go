@@ -0,0 +1,83 @@
+package fcache
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSON导出格式, 时间均为unix纳秒, expiration为0表示永不过期:
+//
+//	{
+//	  "version": 1,
+//	  "saved_at": 1700000000000000000,
+//	  "items": {
+//	    "key": {"value": ..., "expiration": 0, "created": 1700000000000000000, "ttl": 0}
+//	  }
+//	}
+//
+// 值按encoding/json编码, 读回时数字为int64或float64, 对象为map[string]interface{}
+const jsonFormatVersion = 1
+
+type jsonDump struct {
+	Version int                 `json:"version"`
+	SavedAt int64               `json:"saved_at"`
+	Items   map[string]jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	Value      interface{} `json:"value"`
+	Expiration int64       `json:"expiration"`
+	Created    int64       `json:"created,omitempty"`
+	TTL        int64       `json:"ttl,omitempty"`
+}
+
+// 与Save相同, 固定使用JSONCodec
+func (c *Cache) SaveJSON(w io.Writer) error {
+	return JSONCodec{}.Encode(w, c.Items())
+}
+
+func (c *Cache) SaveJSONToFile(file string) error {
+	return c.saveFile(file, c.SaveJSON)
+}
+
+// 与Load相同, 已存在且未过期的key不会被覆盖
+func (c *Cache) LoadJSON(r io.Reader, opts ...LoadOption) error {
+	if c.closed() {
+		return ErrClosed
+	}
+	r, err := decompressed(r)
+	if err != nil {
+		return err
+	}
+	items, err := JSONCodec{}.Decode(r)
+	if err != nil {
+		return err
+	}
+	c.load(items, opts...)
+	return nil
+}
+
+func (c *Cache) LoadJSONFromFile(file string, opts ...LoadOption) error {
+	return c.loadFile(file, func(r io.Reader) error { return c.LoadJSON(r, opts...) })
+}
+
+// 将json.Number还原为int64或float64, 使计数器读回后仍可Increment
+func fromJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = fromJSON(e)
+		}
+	case []interface{}:
+		for i, e := range x {
+			x[i] = fromJSON(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,130 @@
+package fcache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// 以K为key, V为值的Cache视图, key经hasher转换为底层Cache的string key
+// 不同的key必须转换为不同的string, 同一个key必须始终转换为相同的string
+type CacheK[K comparable, V any] struct {
+	c      *Cache
+	hasher func(K) string
+}
+
+// hasher为nil时使用DefaultKeyHasher
+func NewCacheK[K comparable, V any](c *Cache, hasher func(K) string) *CacheK[K, V] {
+	if hasher == nil {
+		hasher = DefaultKeyHasher[K]
+	}
+	return &CacheK[K, V]{c: c, hasher: hasher}
+}
+
+// 返回底层的Cache
+func (t *CacheK[K, V]) Cache() *Cache {
+	return t.c
+}
+
+func (t *CacheK[K, V]) Set(k K, v V, d time.Duration) {
+	t.c.Set(t.hasher(k), v, d)
+}
+
+func (t *CacheK[K, V]) Add(k K, v V, d time.Duration) error {
+	return t.c.Add(t.hasher(k), v, d)
+}
+
+func (t *CacheK[K, V]) Update(k K, v V, d time.Duration) error {
+	return t.c.Update(t.hasher(k), v, d)
+}
+
+// 值不是V类型时返回零值和false
+func (t *CacheK[K, V]) Get(k K) (V, bool) {
+	var zero V
+	v, ok := t.c.Get(t.hasher(k))
+	if !ok {
+		return zero, false
+	}
+	tv, ok := v.(V)
+	if !ok {
+		return zero, false
+	}
+	return tv, true
+}
+
+func (t *CacheK[K, V]) GetOrCompute(k K, ttl time.Duration, loader func() (V, error)) (V, error) {
+	var zero V
+	v, err := t.c.GetOrCompute(t.hasher(k), ttl, func() (interface{}, error) {
+		return loader()
+	})
+	if err != nil {
+		return zero, err
+	}
+	tv, ok := v.(V)
+	if !ok {
+		return zero, fmt.Errorf("%w: %T", ErrTypeMismatch, v)
+	}
+	return tv, nil
+}
+
+func (t *CacheK[K, V]) Delete(k K) {
+	t.c.Delete(t.hasher(k))
+}
+
+// string和整数类型直接转换, 由这些类型以及bool, 浮点数和数组组成的结构体按字段依次编码为二进制, 不经过fmt
+// 其他类型退回到fmt.Sprintf("%#v"); 不同K类型的key可能相同, 共享同一个Cache时应配合Namespace使用
+func DefaultKeyHasher[K comparable](k K) string {
+	switch x := any(k).(type) {
+	case string:
+		return x
+	case int:
+		return string(binary.AppendVarint(nil, int64(x)))
+	case int64:
+		return string(binary.AppendVarint(nil, x))
+	case uint64:
+		return string(binary.AppendUvarint(nil, x))
+	}
+	if b, ok := appendKey(nil, reflect.ValueOf(k)); ok {
+		return string(b)
+	}
+	return fmt.Sprintf("%#v", k)
+}
+
+func appendKey(b []byte, v reflect.Value) ([]byte, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		// 带长度前缀, 避免相邻字段拼接后相同
+		b = binary.AppendUvarint(b, uint64(v.Len()))
+		return append(b, v.String()...), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(b, v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(b, v.Uint()), true
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 1), true
+		}
+		return append(b, 0), true
+	case reflect.Float32, reflect.Float64:
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v.Float())), true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			var ok bool
+			if b, ok = appendKey(b, v.Index(i)); !ok {
+				return nil, false
+			}
+		}
+		return b, true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			var ok bool
+			if b, ok = appendKey(b, v.Field(i)); !ok {
+				return nil, false
+			}
+		}
+		return b, true
+	}
+	return nil, false
+}
@@ -0,0 +1,63 @@
+package fcache
+
+import "sync"
+
+// 按key加锁, 不同key互不阻塞; 零值可以直接使用
+// 锁与缓存条目无关, 只用于串行化调用方自己的计算和写入
+type KeyMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// 阻塞直到获得k的锁, 返回的函数用于解锁, 只能调用一次
+func (m *KeyMutex) Lock(k string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = map[string]*keyLock{}
+	}
+	l, ok := m.locks[k]
+	if !ok {
+		l = &keyLock{}
+		m.locks[k] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+	l.mu.Lock()
+	return func() { m.unlock(k, l) }
+}
+
+// 获取失败时返回nil
+func (m *KeyMutex) TryLock(k string) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.locks[k]; ok {
+		return nil
+	}
+	if m.locks == nil {
+		m.locks = map[string]*keyLock{}
+	}
+	l := &keyLock{refs: 1}
+	l.mu.Lock()
+	m.locks[k] = l
+	return func() { m.unlock(k, l) }
+}
+
+// 没有goroutine持有或等待时删除该key的锁
+func (m *KeyMutex) unlock(k string, l *keyLock) {
+	l.mu.Unlock()
+	m.mu.Lock()
+	if l.refs--; l.refs == 0 {
+		delete(m.locks, k)
+	}
+	m.mu.Unlock()
+}
+
+// 使用cache内置的KeyMutex对k加锁, 见KeyMutex.Lock
+func (c *Cache) LockKey(k string) func() {
+	return c.keyMu.Lock(k)
+}
@@ -0,0 +1,44 @@
+package fcache
+
+import "time"
+
+// 最小的日志接口, *slog.Logger满足该接口
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// 记录过期清理, 淘汰过多, 持久化失败和loader失败, 参数为slog风格的键值对
+func WithLogger(l Logger) Option {
+	return func(o *options) { o.logger = l }
+}
+
+// 一个过期清理间隔内淘汰超过n个条目时记录警告, 需同时设置WithLogger, 小于等于0表示不检查
+func WithEvictionWarning(n int) Option {
+	return func(o *options) { o.evictWarn = n }
+}
+
+func (c *Cache) SetLogger(l Logger) {
+	c.configure(func(cfg *config) { cfg.logger = l })
+}
+
+func (c *Cache) debug(msg string, args ...interface{}) {
+	if l := c.conf().logger; l != nil {
+		l.Debug(msg, args...)
+	}
+}
+
+func (c *Cache) warn(msg string, args ...interface{}) {
+	if l := c.conf().logger; l != nil {
+		l.Warn(msg, args...)
+	}
+}
+
+// 在gcLoop中每个间隔调用一次, last为上一次调用时的淘汰数
+func (c *Cache) checkEvictions(last uint64, interval time.Duration) uint64 {
+	n := c.Stats().Evictions
+	if max := c.conf().evictWarn; max > 0 && n > last && n-last > uint64(max) {
+		c.warn("fcache: high eviction rate", "evictions", n-last, "interval", interval, "entries", c.Count())
+	}
+	return n
+}
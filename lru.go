@@ -0,0 +1,119 @@
+package fcache
+
+import (
+	"container/list"
+	"sort"
+	"sync/atomic"
+)
+
+// 按访问顺序记录key, 队头为最近访问
+type lruList struct {
+	ll    *list.List
+	elems map[string]*list.Element
+}
+
+func newLRUList() *lruList {
+	return &lruList{
+		ll:    list.New(),
+		elems: map[string]*list.Element{},
+	}
+}
+
+func (l *lruList) touch(k string) {
+	if e, ok := l.elems[k]; ok {
+		l.ll.MoveToFront(e)
+		return
+	}
+	l.elems[k] = l.ll.PushFront(k)
+}
+
+func (l *lruList) remove(k string) {
+	if e, ok := l.elems[k]; ok {
+		l.ll.Remove(e)
+		delete(l.elems, k)
+	}
+}
+
+// 从最久未访问的key开始遍历, f返回false时停止
+func (l *lruList) eachOldest(f func(k string) bool) {
+	for e := l.ll.Back(); e != nil; {
+		prev := e.Prev()
+		if !f(e.Value.(string)) {
+			return
+		}
+		e = prev
+	}
+}
+
+// 按淘汰策略淘汰一个未被引用也没有被固定的key, 跳过skip
+func (s *shard) evictOne(skip string) bool {
+	evicted := false
+	s.policy.eachOldest(func(k string) bool {
+		if k == skip || s.refs[k] > 0 || s.items[k].pinned {
+			return true
+		}
+		evicted = s.delete(k, Evicted)
+		return !evicted
+	})
+	if evicted {
+		atomic.AddUint64(&s.c.stats.evictions, 1)
+	}
+	return evicted
+}
+
+// 超出内存上限或开销预算时先在当前shard内淘汰, 不淘汰刚写入的key
+func (s *shard) evictMemory(skip string) {
+	for s.c.overBudget() && s.evictOne(skip) {
+	}
+}
+
+func (c *Cache) overBudget() bool {
+	cfg := c.conf()
+	if cfg.maxCost > 0 && atomic.LoadInt64(&c.cost) > cfg.maxCost {
+		return true
+	}
+	return cfg.maxMemory > 0 && atomic.LoadInt64(&c.memUsage) > cfg.maxMemory
+}
+
+func (c *Cache) overLimit() bool {
+	cfg := c.conf()
+	if cfg.maxEntries > 0 && cfg.overflow == EvictOnFull && atomic.LoadInt64(&c.count) > int64(cfg.maxEntries) {
+		return true
+	}
+	return c.overBudget()
+}
+
+// 在锁外将条目数和内存占用收缩到上限以内
+func (c *Cache) shrink() {
+	for c.overLimit() && c.evictLargest(nil) {
+	}
+	c.shrinkPrefixes()
+}
+
+// 从条目最多的shard开始尝试淘汰一个key, 调用时不能持有任何shard的锁
+func (c *Cache) evictLargest(skip *shard) bool {
+	var candidates []*shard
+	for _, s := range c.shards {
+		if s != skip {
+			candidates = append(candidates, s)
+		}
+	}
+	sizes := make(map[*shard]int, len(candidates))
+	for _, s := range candidates {
+		s.mu.RLock()
+		sizes[s] = len(s.items)
+		s.mu.RUnlock()
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return sizes[candidates[i]] > sizes[candidates[j]]
+	})
+	for _, s := range candidates {
+		s.mu.Lock()
+		evicted := s.evictOne("")
+		s.unlock()
+		if evicted {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package fcache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// 按ops依次执行, "+k"写入, "k"读取, 返回剩余的key
+func runEviction(p EvictionPolicy, max int, ops []string) []string {
+	c := New(WithShards(1), WithMaxEntries(max), WithEvictionPolicy(p), WithGCInterval(0))
+	for _, op := range ops {
+		if op[0] == '+' {
+			c.Set(op[1:], op, NoExpiration)
+		} else {
+			c.Get(op)
+		}
+	}
+	keys := c.Keys()
+	sort.Strings(keys)
+	return keys
+}
+
+func TestLRUEvictionOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []string
+		want []string
+	}{
+		{"insertion order", []string{"+a", "+b", "+c", "+d"}, []string{"b", "c", "d"}},
+		{"read refreshes", []string{"+a", "+b", "+c", "a", "+d"}, []string{"a", "c", "d"}},
+		{"overwrite refreshes", []string{"+a", "+b", "+c", "+a", "+d", "+e"}, []string{"a", "d", "e"}},
+		{"miss does not count", []string{"+a", "+b", "+c", "x", "+d"}, []string{"b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		if got := runEviction(LRU, 3, tt.ops); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keys = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
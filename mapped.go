@@ -0,0 +1,227 @@
+package fcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// 格式: magic "FCMM" | version uint16 | 每个条目的key和gob编码的Item
+// | 按key排序的索引, 每项为key位置uint64, key长度uint32, 值位置uint64, 值长度uint32
+// | 索引位置uint64 | 条目数uint64 | magic "FCMM"
+const (
+	mappedMagic     = "FCMM"
+	mappedVersion   = 1
+	mappedHeader    = len(mappedMagic) + 2
+	mappedIndexSize = 24
+	mappedTrailer   = 16 + len(mappedMagic)
+)
+
+// 以可内存映射的格式保存, 见AttachMapped
+func (c *Cache) SaveMapped(w io.Writer) error {
+	type span struct {
+		key    string
+		keyOff uint64
+		valOff uint64
+		valLen uint32
+	}
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(mappedMagic); err != nil {
+		return err
+	}
+	binary.Write(bw, binary.BigEndian, uint16(mappedVersion))
+	off := uint64(mappedHeader)
+	var spans []span
+	var buf bytes.Buffer
+	for k, v := range c.Items() {
+		register(v.Object)
+		buf.Reset()
+		if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+			return err
+		}
+		bw.WriteString(k)
+		if _, err := bw.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		spans = append(spans, span{key: k, keyOff: off, valOff: off + uint64(len(k)), valLen: uint32(buf.Len())})
+		off += uint64(len(k) + buf.Len())
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].key < spans[j].key })
+	var entry [mappedIndexSize]byte
+	for _, sp := range spans {
+		binary.BigEndian.PutUint64(entry[0:], sp.keyOff)
+		binary.BigEndian.PutUint32(entry[8:], uint32(len(sp.key)))
+		binary.BigEndian.PutUint64(entry[12:], sp.valOff)
+		binary.BigEndian.PutUint32(entry[20:], sp.valLen)
+		if _, err := bw.Write(entry[:]); err != nil {
+			return err
+		}
+	}
+	var trailer [16]byte
+	binary.BigEndian.PutUint64(trailer[0:], off)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(spans)))
+	bw.Write(trailer[:])
+	if _, err := bw.WriteString(mappedMagic); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// 不支持压缩和加密
+func (c *Cache) SaveMappedToFile(file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := c.SaveMapped(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type mappedSnapshot struct {
+	data  []byte
+	index []byte
+	count int
+	unmap func() error
+	// 保护data, 释放映射后不再读取
+	dataMu sync.RWMutex
+	mu     sync.Mutex
+	// 映射之后被写入或删除的key, 以及被清空的shard, 不再从映射中读取
+	shadowed map[string]struct{}
+	flushed  map[int]bool
+}
+
+// 内存映射SaveMapped保存的文件而不解码, Get未命中时才从映射中解码该条目并放回内存
+// 需在缓存开始使用之前调用; Count, Keys和Range等只反映已放回内存的条目, 映射在Close时释放
+func (c *Cache) AttachMapped(file string) error {
+	if c.closed() {
+		return ErrClosed
+	}
+	if c.mapped != nil {
+		return fmt.Errorf("Mapped snapshot already attached")
+	}
+	data, unmap, err := mmapFile(file)
+	if err != nil {
+		return err
+	}
+	m, err := parseMapped(data)
+	if err != nil {
+		unmap()
+		return err
+	}
+	m.unmap = unmap
+	c.mapped = m
+	return nil
+}
+
+func parseMapped(data []byte) (*mappedSnapshot, error) {
+	if len(data) < mappedHeader+mappedTrailer || string(data[:len(mappedMagic)]) != mappedMagic ||
+		string(data[len(data)-len(mappedMagic):]) != mappedMagic {
+		return nil, fmt.Errorf("%w: bad mapped snapshot header", ErrIncompatibleSnapshot)
+	}
+	if v := binary.BigEndian.Uint16(data[len(mappedMagic):]); v != mappedVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrIncompatibleSnapshot, v, mappedVersion)
+	}
+	trailer := data[len(data)-mappedTrailer:]
+	indexOff := binary.BigEndian.Uint64(trailer[0:])
+	count := binary.BigEndian.Uint64(trailer[8:])
+	end := uint64(len(data) - mappedTrailer)
+	if indexOff > end || (end-indexOff)/mappedIndexSize != count || (end-indexOff)%mappedIndexSize != 0 {
+		return nil, fmt.Errorf("%w: bad mapped snapshot index", ErrCorruptSnapshot)
+	}
+	// 检查每个条目的key和值都在数据区内, 之后lookup不再检查
+	index := data[indexOff:end]
+	for i := uint64(0); i < count; i++ {
+		e := index[i*mappedIndexSize:]
+		if !mappedSpanValid(binary.BigEndian.Uint64(e), binary.BigEndian.Uint32(e[8:]), indexOff) ||
+			!mappedSpanValid(binary.BigEndian.Uint64(e[12:]), binary.BigEndian.Uint32(e[20:]), indexOff) {
+			return nil, fmt.Errorf("%w: mapped snapshot entry %d out of range", ErrCorruptSnapshot, i)
+		}
+	}
+	return &mappedSnapshot{
+		data:     data,
+		index:    index,
+		count:    int(count),
+		shadowed: map[string]struct{}{},
+		flushed:  map[int]bool{},
+	}, nil
+}
+
+// [off, off+n)是否在头部和索引之间
+func mappedSpanValid(off uint64, n uint32, limit uint64) bool {
+	return off >= uint64(mappedHeader) && off <= limit && uint64(n) <= limit-off
+}
+
+// 在排序的索引中二分查找k
+func (m *mappedSnapshot) lookup(k string) ([]byte, bool) {
+	i := sort.Search(m.count, func(i int) bool {
+		e := m.index[i*mappedIndexSize:]
+		off, n := binary.BigEndian.Uint64(e), binary.BigEndian.Uint32(e[8:])
+		return string(m.data[off:off+uint64(n)]) >= k
+	})
+	if i == m.count {
+		return nil, false
+	}
+	e := m.index[i*mappedIndexSize:]
+	off, n := binary.BigEndian.Uint64(e), binary.BigEndian.Uint32(e[8:])
+	if string(m.data[off:off+uint64(n)]) != k {
+		return nil, false
+	}
+	voff, vn := binary.BigEndian.Uint64(e[12:]), binary.BigEndian.Uint32(e[20:])
+	return m.data[voff : voff+uint64(vn)], true
+}
+
+func (m *mappedSnapshot) read(k string) (Item, bool) {
+	m.dataMu.RLock()
+	defer m.dataMu.RUnlock()
+	if m.data == nil {
+		return Item{}, false
+	}
+	val, ok := m.lookup(k)
+	if !ok {
+		return Item{}, false
+	}
+	var item Item
+	if gob.NewDecoder(bytes.NewReader(val)).Decode(&item) != nil {
+		return Item{}, false
+	}
+	return item, true
+}
+
+func (m *mappedSnapshot) close() error {
+	m.dataMu.Lock()
+	defer m.dataMu.Unlock()
+	if m.data == nil {
+		return nil
+	}
+	m.data, m.index = nil, nil
+	return m.unmap()
+}
+
+// 调用时需持有对应shard的写锁
+func (m *mappedSnapshot) shadow(k string) {
+	m.mu.Lock()
+	m.shadowed[k] = struct{}{}
+	m.mu.Unlock()
+}
+
+func (m *mappedSnapshot) flushShard(i int) {
+	m.mu.Lock()
+	m.flushed[i] = true
+	m.mu.Unlock()
+}
+
+func (m *mappedSnapshot) valid(s *shard, k string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, shadowed := m.shadowed[k]
+	return !shadowed && !m.flushed[s.id]
+}
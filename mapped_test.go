@@ -0,0 +1,52 @@
+package fcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestParseMappedCorrupt(t *testing.T) {
+	c := New(WithGCInterval(0))
+	c.Set("a", 1, NoExpiration)
+	c.Set("b", "two", NoExpiration)
+	var buf bytes.Buffer
+	if err := c.SaveMapped(&buf); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+	indexOff := binary.BigEndian.Uint64(good[len(good)-mappedTrailer:])
+
+	// 修改第一个索引项中pos处的字段
+	entry := func(pos int, put func(b []byte)) []byte {
+		data := append([]byte(nil), good...)
+		put(data[indexOff+uint64(pos):])
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"valid", good, nil},
+		{"truncated", good[:len(good)-1], ErrIncompatibleSnapshot},
+		{"key offset", entry(0, func(b []byte) { binary.BigEndian.PutUint64(b, uint64(len(good))) }), ErrCorruptSnapshot},
+		{"key length", entry(8, func(b []byte) { binary.BigEndian.PutUint32(b, 1<<31) }), ErrCorruptSnapshot},
+		{"value offset", entry(12, func(b []byte) { binary.BigEndian.PutUint64(b, ^uint64(0)) }), ErrCorruptSnapshot},
+		{"value length", entry(20, func(b []byte) { binary.BigEndian.PutUint32(b, ^uint32(0)) }), ErrCorruptSnapshot},
+		{"value past index", entry(20, func(b []byte) { binary.BigEndian.PutUint32(b, uint32(indexOff)) }), ErrCorruptSnapshot},
+	}
+	for _, tt := range tests {
+		m, err := parseMapped(tt.data)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil {
+			if item, ok := m.read("b"); !ok || item.Object != "two" {
+				t.Errorf("%s: read b = %v, %v", tt.name, item.Object, ok)
+			}
+		}
+	}
+}
@@ -0,0 +1,396 @@
+// memcached文本协议服务, 支持get/gets/set/add/replace/cas/delete/incr/decr/touch/flush_all/version/quit
+// 现有的memcached客户端可以直接把Cache当作本地memcached使用
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fredalxin/fcache"
+)
+
+const (
+	// 单个值的大小上限, 与memcached默认的1MB一致
+	maxValue = 1 << 20
+	// exptime大于30天时表示unix时间戳
+	relativeLimit = 30 * 24 * 3600
+	// 命令行的长度上限, 超过时关闭连接
+	maxLine = 64 << 10
+	// incr/decr与并发写入冲突时的最大重试次数
+	maxIncrRetries = 100
+)
+
+// flags不为0时以Value保存, 否则直接保存[]byte
+type Value struct {
+	Flags uint32
+	Data  []byte
+}
+
+type Server struct {
+	cache *fcache.Cache
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+func New(c *fcache.Cache) *Server {
+	return &Server{
+		cache:     c,
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// 阻塞直到l出错或Close被调用
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return fcache.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// 关闭所有监听和连接, 不关闭底层的Cache
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	r := bufio.NewReaderSize(conn, maxLine)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			w.WriteString("CLIENT_ERROR line too long\r\n")
+			w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			w.WriteString("ERROR\r\n")
+			w.Flush()
+			continue
+		}
+		if fields[0] == "quit" {
+			return
+		}
+		if err := s.handle(r, w, fields); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+var errProtocol = errors.New("protocol error")
+
+// 只有连接无法继续使用时返回错误
+func (s *Server) handle(r *bufio.Reader, w *bufio.Writer, f []string) error {
+	cmd, args := f[0], f[1:]
+	noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+	if noreply {
+		args = args[:len(args)-1]
+	}
+	reply := func(msg string) {
+		if !noreply {
+			w.WriteString(msg + "\r\n")
+		}
+	}
+	switch cmd {
+	case "get", "gets":
+		if len(args) == 0 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		for _, k := range args {
+			v, version, ok := s.cache.GetWithVersion(k)
+			if !ok {
+				continue
+			}
+			flags, data := encode(v)
+			if cmd == "gets" {
+				fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", k, flags, len(data), version)
+			} else {
+				fmt.Fprintf(w, "VALUE %s %d %d\r\n", k, flags, len(data))
+			}
+			w.Write(data)
+			w.WriteString("\r\n")
+		}
+		w.WriteString("END\r\n")
+	case "set", "add", "replace", "cas":
+		want := 4
+		if cmd == "cas" {
+			want = 5
+		}
+		if len(args) != want {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		flags, err1 := strconv.ParseUint(args[1], 10, 32)
+		exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+		n, err3 := strconv.Atoi(args[3])
+		if err1 != nil || err2 != nil || err3 != nil || n < 0 {
+			w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return nil
+		}
+		if n > maxValue {
+			// 数据块长度不可信, 无法继续解析该连接
+			w.WriteString("SERVER_ERROR object too large for cache\r\n")
+			return errProtocol
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		if string(data[n:]) != "\r\n" {
+			w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+			return nil
+		}
+		v := decode(uint32(flags), data[:n])
+		d, expired := ttl(exptime)
+		if expired {
+			if cmd != "add" {
+				s.cache.Delete(args[0])
+			}
+			reply("STORED")
+			return nil
+		}
+		switch cmd {
+		case "set":
+			if err := s.cache.SetCtx(context.Background(), args[0], v, d); err != nil {
+				reply("SERVER_ERROR " + err.Error())
+				return nil
+			}
+			reply("STORED")
+		case "add":
+			if err := s.cache.Add(args[0], v, d); errors.Is(err, fcache.ErrKeyExists) {
+				reply("NOT_STORED")
+				return nil
+			} else if err != nil {
+				reply("SERVER_ERROR " + err.Error())
+				return nil
+			}
+			reply("STORED")
+		case "replace":
+			if err := s.cache.Update(args[0], v, d); errors.Is(err, fcache.ErrKeyNotFound) {
+				reply("NOT_STORED")
+				return nil
+			} else if err != nil {
+				reply("SERVER_ERROR " + err.Error())
+				return nil
+			}
+			reply("STORED")
+		case "cas":
+			unique, err := strconv.ParseUint(args[4], 10, 64)
+			if err != nil {
+				reply("CLIENT_ERROR bad command line format")
+				return nil
+			}
+			switch err := s.cache.SetIfVersionErr(args[0], v, unique, d); {
+			case errors.Is(err, fcache.ErrKeyNotFound):
+				reply("NOT_FOUND")
+			case errors.Is(err, fcache.ErrVersionMismatch):
+				reply("EXISTS")
+			case errors.Is(err, fcache.ErrNotStored):
+				reply("NOT_STORED")
+			case err != nil:
+				reply("SERVER_ERROR " + err.Error())
+			default:
+				reply("STORED")
+			}
+		}
+	case "delete":
+		if len(args) != 1 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		if !s.cache.Remove(args[0]) {
+			reply("NOT_FOUND")
+			return nil
+		}
+		reply("DELETED")
+	case "incr", "decr":
+		if len(args) != 2 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		delta, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			reply("CLIENT_ERROR invalid numeric delta argument")
+			return nil
+		}
+		n, err := s.incr(args[0], delta, cmd == "decr")
+		switch {
+		case errors.Is(err, fcache.ErrKeyNotFound):
+			reply("NOT_FOUND")
+		case errors.Is(err, fcache.ErrTypeMismatch):
+			reply("CLIENT_ERROR cannot increment or decrement non-numeric value")
+		case err != nil:
+			reply("SERVER_ERROR " + err.Error())
+		default:
+			reply(strconv.FormatUint(n, 10))
+		}
+	case "touch":
+		if len(args) != 2 {
+			w.WriteString("ERROR\r\n")
+			return nil
+		}
+		exptime, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			reply("CLIENT_ERROR invalid exptime argument")
+			return nil
+		}
+		d, expired := ttl(exptime)
+		if expired {
+			if !s.cache.Remove(args[0]) {
+				reply("NOT_FOUND")
+				return nil
+			}
+			reply("TOUCHED")
+			return nil
+		}
+		if !s.cache.Expire(args[0], d) {
+			reply("NOT_FOUND")
+			return nil
+		}
+		reply("TOUCHED")
+	case "flush_all":
+		s.cache.Flush()
+		reply("OK")
+	case "version":
+		w.WriteString("VERSION fcache\r\n")
+	default:
+		w.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+// 按memcached的规则换算exptime: 0永不过期, 不超过30天为相对秒数, 否则为unix时间戳, 负数表示立即过期
+func ttl(exptime int64) (time.Duration, bool) {
+	switch {
+	case exptime == 0:
+		return fcache.NoExpiration, false
+	case exptime < 0:
+		return 0, true
+	case exptime <= relativeLimit:
+		return time.Duration(exptime) * time.Second, false
+	}
+	d := time.Until(time.Unix(exptime, 0))
+	return d, d <= 0
+}
+
+func decode(flags uint32, data []byte) interface{} {
+	b := append([]byte(nil), data...)
+	if flags == 0 {
+		return b
+	}
+	return Value{Flags: flags, Data: b}
+}
+
+// 非memcached写入的值: string原样返回, 其他类型以fmt格式化
+func encode(v interface{}) (uint32, []byte) {
+	switch x := v.(type) {
+	case Value:
+		return x.Flags, x.Data
+	case []byte:
+		return 0, x
+	case string:
+		return 0, []byte(x)
+	}
+	return 0, []byte(fmt.Sprint(v))
+}
+
+// 值按十进制无符号整数解析, decr不会低于0, incr按64位回绕
+// key不存在时返回ErrKeyNotFound, 值不是数值时返回ErrTypeMismatch
+func (s *Server) incr(k string, delta uint64, decr bool) (uint64, error) {
+	for i := 0; i < maxIncrRetries; i++ {
+		v, version, found := s.cache.GetWithVersion(k)
+		if !found {
+			return 0, fcache.ErrKeyNotFound
+		}
+		flags, data := encode(v)
+		n, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return 0, fcache.ErrTypeMismatch
+		}
+		if decr {
+			if delta > n {
+				n = 0
+			} else {
+				n -= delta
+			}
+		} else {
+			n += delta
+		}
+		d := fcache.NoExpiration
+		if left, ok := s.cache.TTL(k); ok && left != fcache.NoExpiration {
+			if left <= 0 {
+				return 0, fcache.ErrKeyNotFound
+			}
+			d = left
+		}
+		err = s.cache.SetIfVersionErr(k, decode(flags, []byte(strconv.FormatUint(n, 10))), version, d)
+		// 读取之后被其他写入修改或删除时重试, 其他原因的失败直接返回
+		if err == nil || !errors.Is(err, fcache.ErrVersionMismatch) && !errors.Is(err, fcache.ErrKeyNotFound) {
+			return n, err
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", fcache.ErrConflict, k)
+}
@@ -0,0 +1,77 @@
+package memcached
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fredalxin/fcache"
+)
+
+// 发送in和quit, 返回连接关闭前收到的全部回复
+func roundTrip(t *testing.T, s *Server, in string) string {
+	client, server := net.Pipe()
+	go s.serveConn(server)
+	go io.WriteString(client, in+"quit\r\n")
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	out, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("read %q: %v", in, err)
+	}
+	return string(out)
+}
+
+func TestProtocol(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"set get", "set a 5 0 2\r\nhi\r\nget a\r\n", "STORED\r\nVALUE a 5 2\r\nhi\r\nEND\r\n"},
+		{"negative length", "set a 0 0 -1\r\n", "CLIENT_ERROR bad command line format\r\n"},
+		{"bad data chunk", "set a 0 0 2\r\nhiXX\r\n", "CLIENT_ERROR bad data chunk\r\nERROR\r\n"},
+		{"delete", "set a 0 0 1\r\nx\r\ndelete a\r\ndelete a\r\n", "STORED\r\nDELETED\r\nNOT_FOUND\r\n"},
+		{"touch expired", "set a 0 0 1\r\nx\r\ntouch a -1\r\ntouch a -1\r\nget a\r\n", "STORED\r\nTOUCHED\r\nNOT_FOUND\r\nEND\r\n"},
+		{"incr", "set n 0 0 1\r\n5\r\nincr n 2\r\ndecr n 10\r\nincr missing 1\r\n", "STORED\r\n7\r\n0\r\nNOT_FOUND\r\n"},
+		{"incr non-numeric", "set a 0 0 1\r\nx\r\nincr a 1\r\n", "STORED\r\nCLIENT_ERROR cannot increment or decrement non-numeric value\r\n"},
+		{"cas", "set a 0 0 1\r\nx\r\ncas a 0 0 1 999999\r\ny\r\ncas b 0 0 1 1\r\ny\r\n", "STORED\r\nEXISTS\r\nNOT_FOUND\r\n"},
+		{"line too long", strings.Repeat("a", maxLine+1), "CLIENT_ERROR line too long\r\n"},
+	}
+	for _, tt := range tests {
+		s := New(fcache.New(fcache.WithGCInterval(0)))
+		if got := roundTrip(t, s, tt.in); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// delete和touch不调用loader
+func TestDeleteSkipsLoader(t *testing.T) {
+	loads := 0
+	c := fcache.New(fcache.WithGCInterval(0), fcache.WithLoader(func(ctx context.Context, k string) (interface{}, time.Duration, error) {
+		loads++
+		return []byte("loaded"), time.Hour, nil
+	}))
+	s := New(c)
+	if got := roundTrip(t, s, "delete a\r\ntouch a -1\r\n"); got != "NOT_FOUND\r\nNOT_FOUND\r\n" {
+		t.Errorf("got %q", got)
+	}
+	if loads != 0 {
+		t.Fatalf("loader called %d times", loads)
+	}
+}
+
+// 写入被拒绝时incr和cas返回SERVER_ERROR而不是重试
+func TestWriteRejected(t *testing.T) {
+	c := fcache.New(fcache.WithGCInterval(0))
+	s := New(c)
+	roundTrip(t, s, "set n 0 0 1\r\n1\r\n")
+	c.SetReadOnly(true)
+	got := roundTrip(t, s, "incr n 1\r\ncas n 0 0 1 1\r\n2\r\n")
+	if want := "SERVER_ERROR fcache: read only\r\nSERVER_ERROR fcache: read only\r\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,125 @@
+// prometheus指标导出, 每个Cache实例以cache标签区分
+package metrics
+
+import (
+	"github.com/fredalxin/fcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Collector struct {
+	cache *fcache.Cache
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	hitRatio   *prometheus.Desc
+	sets       *prometheus.Desc
+	deletes    *prometheus.Desc
+	evictions  *prometheus.Desc
+	expired    *prometheus.Desc
+	entries    *prometheus.Desc
+	memory     *prometheus.Desc
+	gcRuns     *prometheus.Desc
+	gcSeconds  *prometheus.Desc
+	loads      *prometheus.Desc
+	loadErrs   *prometheus.Desc
+	loadPanics *prometheus.Desc
+	loadTime   *prometheus.Desc
+	coalesced  *prometheus.Desc
+	inFlight   *prometheus.Desc
+	waiters    *prometheus.Desc
+	pinned     *prometheus.Desc
+}
+
+// 导出memory_bytes需要估算条目大小, 会开启c的内存统计
+func NewCollector(name string, c *fcache.Cache) *Collector {
+	c.SetMemoryTracking(true)
+	labels := prometheus.Labels{"cache": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("fcache", "", metric), help, nil, labels)
+	}
+	return &Collector{
+		cache:      c,
+		hits:       desc("hits_total", "Number of cache hits."),
+		misses:     desc("misses_total", "Number of cache misses."),
+		hitRatio:   desc("hit_ratio", "Ratio of hits to total reads."),
+		sets:       desc("sets_total", "Number of writes."),
+		deletes:    desc("deletes_total", "Number of explicit deletes."),
+		evictions:  desc("evictions_total", "Number of items evicted by capacity limits."),
+		expired:    desc("expired_total", "Number of expired items removed by GC."),
+		entries:    desc("entries", "Current number of items."),
+		memory:     desc("memory_bytes", "Estimated memory used by items."),
+		gcRuns:     desc("gc_runs_total", "Number of expiration sweeps."),
+		gcSeconds:  desc("gc_duration_seconds_total", "Total time spent in expiration sweeps."),
+		loads:      desc("loads_total", "Number of loader calls."),
+		loadErrs:   desc("load_errors_total", "Number of loader calls that returned an error."),
+		loadPanics: desc("load_panics_total", "Number of loader calls that panicked."),
+		loadTime:   desc("load_duration_seconds", "Loader call durations."),
+		coalesced:  desc("coalesced_total", "Number of callers that joined an in-flight load."),
+		inFlight:   desc("loads_in_flight", "Current number of keys being loaded."),
+		waiters:    desc("load_waiters", "Current number of callers waiting on in-flight loads."),
+		pinned:     desc("pinned_entries", "Current number of pinned items."),
+	}
+}
+
+func (m *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.hits
+	ch <- m.misses
+	ch <- m.hitRatio
+	ch <- m.sets
+	ch <- m.deletes
+	ch <- m.evictions
+	ch <- m.expired
+	ch <- m.entries
+	ch <- m.memory
+	ch <- m.gcRuns
+	ch <- m.gcSeconds
+	ch <- m.loads
+	ch <- m.loadErrs
+	ch <- m.loadPanics
+	ch <- m.loadTime
+	ch <- m.coalesced
+	ch <- m.inFlight
+	ch <- m.waiters
+	ch <- m.pinned
+}
+
+func (m *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := m.cache.Stats()
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter(m.hits, float64(s.Hits))
+	counter(m.misses, float64(s.Misses))
+	gauge(m.hitRatio, s.HitRatio())
+	counter(m.sets, float64(s.Sets))
+	counter(m.deletes, float64(s.Deletes))
+	counter(m.evictions, float64(s.Evictions))
+	counter(m.expired, float64(s.Expired))
+	gauge(m.entries, float64(m.cache.Count()))
+	gauge(m.memory, float64(m.cache.MemoryUsage()))
+	counter(m.gcRuns, float64(s.GCRuns))
+	counter(m.gcSeconds, s.GCTime.Seconds())
+	counter(m.loads, float64(s.Loads))
+	counter(m.loadErrs, float64(s.LoadErrors))
+	counter(m.loadPanics, float64(s.LoadPanics))
+	counter(m.coalesced, float64(s.Coalesced))
+	gauge(m.inFlight, float64(s.InFlightLoads))
+	gauge(m.waiters, float64(s.LoadWaiters))
+	gauge(m.pinned, float64(s.Pinned))
+	// prometheus的桶是累加的
+	buckets := make(map[float64]uint64, len(s.LoadBuckets))
+	var cum uint64
+	for i, b := range s.LoadBuckets {
+		cum += s.LoadCounts[i]
+		buckets[b.Seconds()] = cum
+	}
+	ch <- prometheus.MustNewConstHistogram(m.loadTime, s.Loads, s.LoadTime.Seconds(), buckets)
+}
+
+// 注册到默认的prometheus registry
+func Register(name string, c *fcache.Cache) error {
+	return prometheus.Register(NewCollector(name, c))
+}
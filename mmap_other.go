@@ -0,0 +1,14 @@
+//go:build !unix
+
+package fcache
+
+import "os"
+
+// 不支持mmap的平台整体读入内存, 条目仍在首次读取时才解码
+func mmapFile(file string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
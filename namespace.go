@@ -0,0 +1,98 @@
+package fcache
+
+import (
+	"strings"
+	"time"
+)
+
+// 命名空间与key之间的分隔符
+const namespaceSep = ":"
+
+// 共享同一个Cache的key前缀视图, 读写时自动加上"name:"前缀
+type Namespace struct {
+	c      *Cache
+	prefix string
+}
+
+func (c *Cache) Namespace(name string) *Namespace {
+	return &Namespace{c: c, prefix: name + namespaceSep}
+}
+
+// 返回底层的Cache
+func (n *Namespace) Cache() *Cache {
+	return n.c
+}
+
+// 嵌套的命名空间, 前缀为"parent:name:"
+func (n *Namespace) Namespace(name string) *Namespace {
+	return &Namespace{c: n.c, prefix: n.prefix + name + namespaceSep}
+}
+
+func (n *Namespace) Set(k string, v interface{}, d time.Duration) {
+	n.c.Set(n.prefix+k, v, d)
+}
+
+func (n *Namespace) Add(k string, v interface{}, d time.Duration) error {
+	return n.c.Add(n.prefix+k, v, d)
+}
+
+func (n *Namespace) Update(k string, v interface{}, d time.Duration) error {
+	return n.c.Update(n.prefix+k, v, d)
+}
+
+func (n *Namespace) Get(k string) (interface{}, bool) {
+	return n.c.Get(n.prefix + k)
+}
+
+func (n *Namespace) Increment(k string, delta int64) (interface{}, error) {
+	return n.c.Increment(n.prefix+k, delta)
+}
+
+func (n *Namespace) Delete(k string) {
+	n.c.Delete(n.prefix + k)
+}
+
+// 返回去掉前缀的key
+func (n *Namespace) Keys() []string {
+	var keys []string
+	n.Range(func(k string, v interface{}) bool {
+		keys = append(keys, k)
+		return true
+	})
+	return keys
+}
+
+func (n *Namespace) Range(f func(k string, v interface{}) bool) {
+	n.c.Range(func(k string, v interface{}) bool {
+		if !strings.HasPrefix(k, n.prefix) {
+			return true
+		}
+		return f(k[len(n.prefix):], v)
+	})
+}
+
+// 清空该命名空间及其嵌套的命名空间
+func (n *Namespace) Flush() {
+	n.c.flushPrefix(n.prefix)
+}
+
+// 只清空name下的key, 其他key不受影响
+func (c *Cache) FlushNamespace(name string) {
+	c.flushPrefix(name + namespaceSep)
+}
+
+func (c *Cache) flushPrefix(prefix string) {
+	if c.writable() != nil {
+		return
+	}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for k := range s.items {
+			if strings.HasPrefix(k, prefix) {
+				s.delete(k, Flushed)
+			}
+		}
+		s.unlock()
+	}
+	c.notifySpace()
+}
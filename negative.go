@@ -0,0 +1,59 @@
+package fcache
+
+import "sync"
+
+type negativeEntry struct {
+	err        error
+	expiration int64
+}
+
+// 缓存加载失败的错误, 与正常条目分开存放, 不参与容量限制和持久化
+type negativeCache struct {
+	mu sync.Mutex
+	m  map[string]negativeEntry
+}
+
+func (n *negativeCache) get(k string, now int64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	e, ok := n.m[k]
+	if !ok {
+		return nil
+	}
+	if now > e.expiration {
+		delete(n.m, k)
+		return nil
+	}
+	return e.err
+}
+
+func (n *negativeCache) set(k string, err error, expiration int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.m == nil {
+		n.m = map[string]negativeEntry{}
+	}
+	n.m[k] = negativeEntry{err: err, expiration: expiration}
+}
+
+func (n *negativeCache) delete(k string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.m, k)
+}
+
+func (n *negativeCache) deleteExpired(now int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, e := range n.m {
+		if now > e.expiration {
+			delete(n.m, k)
+		}
+	}
+}
+
+func (n *negativeCache) flush() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.m = nil
+}
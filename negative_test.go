@@ -0,0 +1,59 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNegativeCaching(t *testing.T) {
+	errDown := errors.New("backend down")
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		steps []time.Duration
+		// 每步之后loader的累计调用次数
+		calls []int
+	}{
+		{"disabled", 0, []time.Duration{0, time.Second}, []int{1, 2}},
+		{"cached", 10 * time.Second, []time.Duration{0, time.Second, 5 * time.Second}, []int{1, 1, 1}},
+		{"expires", 10 * time.Second, []time.Duration{0, 5 * time.Second, 6 * time.Second}, []int{1, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			calls := 0
+			c := New(WithClock(clock), WithGCInterval(0), WithNegativeTTL(tt.ttl), WithLoader(func(ctx context.Context, k string) (interface{}, time.Duration, error) {
+				calls++
+				return nil, 0, errDown
+			}))
+			for i, d := range tt.steps {
+				clock.Advance(d)
+				if _, _, err := c.GetCtx(context.Background(), "k"); !errors.Is(err, errDown) {
+					t.Fatalf("step %d: err %v, want %v", i, err, errDown)
+				}
+				if calls != tt.calls[i] {
+					t.Fatalf("step %d: loader called %d times, want %d", i, calls, tt.calls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNegativeCachingFlush(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithClock(clock), WithGCInterval(0), WithNegativeTTL(time.Minute))
+	fail := func() (interface{}, error) { return nil, errors.New("fail") }
+	if _, err := c.GetOrCompute("k", time.Hour, fail); err == nil {
+		t.Fatal("want error")
+	}
+	// 缓存期间不调用新的loader
+	if _, err := c.GetOrCompute("k", time.Hour, func() (interface{}, error) { return "v", nil }); err == nil {
+		t.Fatal("cached error not returned")
+	}
+	c.Flush()
+	if v, err := c.GetOrCompute("k", time.Hour, func() (interface{}, error) { return "v", nil }); err != nil || v != "v" {
+		t.Fatalf("after Flush: %v, %v", v, err)
+	}
+}
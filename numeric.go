@@ -0,0 +1,92 @@
+package fcache
+
+// 按a的类型将b累加到a上, a或b不是数值类型时返回false
+func addNumber(a, b interface{}) (interface{}, bool) {
+	switch x := a.(type) {
+	case int:
+		n, ok := toInt64(b)
+		return x + int(n), ok
+	case int8:
+		n, ok := toInt64(b)
+		return x + int8(n), ok
+	case int16:
+		n, ok := toInt64(b)
+		return x + int16(n), ok
+	case int32:
+		n, ok := toInt64(b)
+		return x + int32(n), ok
+	case int64:
+		n, ok := toInt64(b)
+		return x + n, ok
+	case uint:
+		n, ok := toInt64(b)
+		return x + uint(n), ok
+	case uint8:
+		n, ok := toInt64(b)
+		return x + uint8(n), ok
+	case uint16:
+		n, ok := toInt64(b)
+		return x + uint16(n), ok
+	case uint32:
+		n, ok := toInt64(b)
+		return x + uint32(n), ok
+	case uint64:
+		n, ok := toInt64(b)
+		return x + uint64(n), ok
+	case uintptr:
+		n, ok := toInt64(b)
+		return x + uintptr(n), ok
+	case float32:
+		f, ok := toFloat64(b)
+		return x + float32(f), ok
+	case float64:
+		f, ok := toFloat64(b)
+		return x + f, ok
+	}
+	return nil, false
+}
+
+func isNumber(v interface{}) bool {
+	_, ok := toFloat64(v)
+	return ok
+}
+
+// 只接受整数类型, 避免浮点数被截断
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	case uintptr:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	i, ok := toInt64(v)
+	return float64(i), ok
+}
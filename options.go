@@ -0,0 +1,254 @@
+package fcache
+
+import (
+	"runtime"
+	"time"
+)
+
+type options struct {
+	config
+	shards           int
+	policy           EvictionPolicy
+	gcInterval       time.Duration
+	autoSavePath     string
+	autoSaveInterval time.Duration
+	clock            Clock
+	lockFreeReads    bool
+	indexes          map[string]IndexFunc
+	diskPath         string
+	prefixes         []PrefixPolicy
+}
+
+type Option func(o *options)
+
+// 默认过期时间, 默认永不过期
+func WithDefaultTTL(d time.Duration) Option {
+	return func(o *options) { o.defaultExpiration = d }
+}
+
+// 过期清理的间隔, 小于等于0时不启动后台清理, 默认1分钟
+func WithGCInterval(d time.Duration) Option {
+	return func(o *options) { o.gcInterval = d }
+}
+
+// 每次后台清理只处理n个shard, 按顺序轮流, 所有shard清理一遍需要shard数/n个间隔; 小于等于0时每次清理全部shard
+func WithGCShardsPerTick(n int) Option {
+	return func(o *options) { o.gcShards = n }
+}
+
+// 读多写少时使用: 每个shard额外维护一个只读副本, Get命中副本时不加锁
+// 命中副本的读取不更新淘汰策略的访问顺序和HottestKeys的读取次数, 开启滑动过期的条目仍加锁读取
+// 新写入的key在副本重建前仍加锁读取, 频繁写入新key时重建副本的开销较大
+func WithLockFreeReads() Option {
+	return func(o *options) { o.lockFreeReads = true }
+}
+
+// shard数量, 默认为不小于GOMAXPROCS*4的2的幂, 最多256
+// 容量淘汰和淘汰策略的访问顺序按shard分别维护, 需要全局严格的淘汰顺序时使用WithShards(1)
+func WithShards(n int) Option {
+	return func(o *options) { o.shards = n }
+}
+
+func defaultShards() int {
+	n := 1
+	for n < runtime.GOMAXPROCS(0)*4 && n < 256 {
+		n <<= 1
+	}
+	return n
+}
+
+// 达到容量上限时选择淘汰对象的策略, 默认LRU
+func WithEvictionPolicy(p EvictionPolicy) Option {
+	return func(o *options) { o.policy = p }
+}
+
+// 读取未命中时调用loader加载, 返回的值以返回的存活时间写入, 返回错误时不写入
+// 开启了key过滤器时, 一定没有写入过的key不调用loader
+func WithLoader(f LoaderFunc) Option {
+	return func(o *options) { o.loader = f }
+}
+
+// 加载失败的错误缓存d时间, 期间同一个key不再调用loader
+func WithNegativeTTL(d time.Duration) Option {
+	return func(o *options) { o.negativeTTL = d }
+}
+
+// 条目距离过期不足window时, Get仍返回当前值, 同时在后台调用loader刷新, 需同时设置WithLoader
+func WithStaleWhileRevalidate(window time.Duration) Option {
+	return func(o *options) { o.staleWindow = window }
+}
+
+func WithTTLJitter(fraction float64) Option {
+	return func(o *options) { o.ttlJitter = clampJitter(fraction) }
+}
+
+// 见SetValueCompression
+func WithValueCompression(threshold int) Option {
+	return func(o *options) { o.compressMin = threshold }
+}
+
+// 见SetCipher
+func WithCipher(ci Cipher) Option {
+	return func(o *options) { o.cipher = ci }
+}
+
+// 见SetCodec
+func WithCodec(cd Codec) Option {
+	return func(o *options) { o.codec = cd }
+}
+
+// 过期判断, 过期清理的周期和写入时间都使用clock, 默认为真实时间
+// 统计耗时, append log的同步和自动保存仍使用真实时间
+func WithClock(clock Clock) Option {
+	return func(o *options) { o.clock = clock }
+}
+
+// 见SetSlidingExpiration
+func WithSlidingExpiration() Option {
+	return func(o *options) { o.sliding = true }
+}
+
+// 所有条目的存活时间不超过d, 包括以NoExpiration写入和从文件加载的条目
+func WithMaxTTL(d time.Duration) Option {
+	return func(o *options) { o.maxTTL = d }
+}
+
+// 条目从写入起最多存活d, 滑动过期和Touch也不能延长, 见Item.Deadline
+func WithMaxLifetime(d time.Duration) Option {
+	return func(o *options) { o.maxLifetime = d }
+}
+
+// Get, Range, Items等返回值的副本, cloner为nil时通过gob深拷贝
+func WithCopyOnRead(cloner func(interface{}) interface{}) Option {
+	return func(o *options) { o.copyOnRead, o.cloner = true, cloner }
+}
+
+// 见Admission, 例如WithAdmission(NewTinyLFU(n))配合WithMaxEntries(n)
+func WithAdmission(a Admission) Option {
+	return func(o *options) { o.admission = a }
+}
+
+// 见SetMaxEntries, 分片时限制所有shard的条目总数
+func WithMaxEntries(n int) Option {
+	return func(o *options) { o.maxEntries = n }
+}
+
+func WithMaxMemory(n int64) Option {
+	return func(o *options) { o.maxMemory = n }
+}
+
+// 见SetMaxCost
+func WithMaxCost(n int64) Option {
+	return func(o *options) { o.maxCost = n }
+}
+
+// 见SetMemoryTracking
+func WithMemoryTracking() Option {
+	return func(o *options) { o.trackMemory = true }
+}
+
+func WithSizeFunc(f func(k string, v interface{}) int64) Option {
+	return func(o *options) { o.sizeFunc = f }
+}
+
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(o *options) { o.overflow = p }
+}
+
+func WithOnEvicted(f func(k string, v interface{})) Option {
+	return func(o *options) { o.onEvicted = f }
+}
+
+func WithOnRemoved(f func(k string, v interface{}, reason Reason)) Option {
+	return func(o *options) { o.onRemoved = f }
+}
+
+func WithExpireArchive(f func(k string, item Item) error, retainOnError bool) Option {
+	return func(o *options) {
+		o.archive = f
+		o.archiveRetain = retainOnError
+	}
+}
+
+func WithKeyDelimiter(delim string) Option {
+	return func(o *options) { o.keyDelimiter = delim }
+}
+
+func WithZeroDurationNoStore() Option {
+	return func(o *options) { o.zeroNoStore = true }
+}
+
+func WithLazyExpiration() Option {
+	return func(o *options) { o.lazyExpire = true }
+}
+
+// 写入时的0和NoExpiration以外的负数存活时间返回ErrAmbiguousTTL, 默认过期时间需通过SetWithDefaultTTL使用
+func WithStrictTTL() Option {
+	return func(o *options) { o.strictTTL = true }
+}
+
+func WithStrictSave() Option {
+	return func(o *options) { o.strictSave = true }
+}
+
+// 启动时从path加载快照, 之后每隔interval及Close时保存快照, interval小于等于0时只在Close时保存
+func WithAutoSave(path string, interval time.Duration) Option {
+	return func(o *options) {
+		o.autoSavePath = path
+		o.autoSaveInterval = interval
+	}
+}
+
+func New(opts ...Option) *Cache {
+	o := &options{
+		config: config{
+			defaultExpiration: NoExpiration,
+		},
+		shards:     defaultShards(),
+		gcInterval: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.shards < 1 {
+		o.shards = 1
+	}
+	c := &Cache{
+		gcInterval:    o.gcInterval,
+		policy:        o.policy,
+		stopGc:        make(chan bool),
+		space:         make(chan struct{}),
+		clock:         o.clock,
+		lockFreeReads: o.lockFreeReads,
+		indexes:       o.indexes,
+		prefixes:      newPrefixRules(o.prefixes),
+	}
+	for _, r := range c.prefixes {
+		if r.MaxMemory > 0 {
+			c.prefixMemory = true
+		}
+	}
+	if c.clock == nil {
+		c.clock = realClock{}
+	}
+	cfg := o.config
+	c.cfg.Store(&cfg)
+	c.shards = make([]*shard, o.shards)
+	for i := range c.shards {
+		c.shards[i] = newShard(c)
+		c.shards[i].id = i
+	}
+	if c.gcInterval > 0 {
+		go c.gcLoop()
+	}
+	if o.diskPath != "" {
+		c.diskErr = c.EnableDiskBackend(o.diskPath)
+	}
+	if o.softLimit > 0 {
+		c.watchHeap()
+	}
+	if o.autoSavePath != "" {
+		c.startAutoSave(o.autoSavePath, o.autoSaveInterval)
+	}
+	return c
+}
@@ -0,0 +1,122 @@
+package fcache
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// 将glob转换为正则: *匹配任意字符串, ?匹配单个字符, [abc]和[a-z]匹配字符集合, \转义下一个字符
+// 与path.Match不同, *可以匹配/和:等任意字符
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '*':
+			b.WriteString("(?s:.*)")
+		case '?':
+			b.WriteString("(?s:.)")
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, fmt.Errorf("Bad pattern %s: trailing backslash", pattern)
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("Bad pattern %s: unclosed [", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// 删除匹配glob的key, 返回删除的数量
+func (c *Cache) DeletePattern(pattern string) (int, error) {
+	re, err := compileGlob(pattern)
+	if err != nil {
+		return 0, err
+	}
+	return c.DeleteRegexp(re), nil
+}
+
+func (c *Cache) DeleteRegexp(re *regexp.Regexp) int {
+	if c.writable() != nil {
+		return 0
+	}
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for k := range s.items {
+			if re.MatchString(k) && s.delete(k, Deleted) {
+				n++
+			}
+		}
+		s.unlock()
+	}
+	atomic.AddUint64(&c.stats.deletes, uint64(n))
+	return n
+}
+
+// 删除f返回true的未过期条目, 返回删除的数量
+// 逐个shard在写锁内调用f, f中不能读写cache
+func (c *Cache) DeleteFunc(f func(k string, v interface{}) bool) int {
+	return c.DeleteItemFunc(func(k string, item Item) bool {
+		return f(k, item.Object)
+	})
+}
+
+// 与DeleteFunc相同, f可以按写入时间, 过期时间等条件判断
+func (c *Cache) DeleteItemFunc(f func(k string, item Item) bool) int {
+	if c.writable() != nil {
+		return 0
+	}
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for k, v := range s.items {
+			if c.expired(v) || s.pending(k) {
+				continue
+			}
+			if f(k, v.unpacked()) && s.delete(k, Deleted) {
+				n++
+			}
+		}
+		s.unlock()
+	}
+	atomic.AddUint64(&c.stats.deletes, uint64(n))
+	return n
+}
+
+// 返回匹配glob的未过期的key
+func (c *Cache) KeysByPattern(pattern string) ([]string, error) {
+	re, err := compileGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return c.KeysByRegexp(re), nil
+}
+
+func (c *Cache) KeysByRegexp(re *regexp.Regexp) []string {
+	var keys []string
+	for _, s := range c.shards {
+		for _, e := range s.snapshot() {
+			if re.MatchString(e.Key) {
+				keys = append(keys, e.Key)
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,45 @@
+package fcache
+
+import "sync/atomic"
+
+// 固定k, 固定期间k不会过期, 也不会被淘汰或因内存紧张被移除; 覆盖写入保留固定状态, 删除或清空后失效
+// k不存在或已过期时返回false
+func (c *Cache) Pin(k string) bool {
+	return c.setPinned(k, true)
+}
+
+// 取消固定, 已超过过期时间的k在下次清理时删除; k不存在或没有被固定时返回false
+func (c *Cache) Unpin(k string) bool {
+	return c.setPinned(k, false)
+}
+
+func (c *Cache) setPinned(k string, on bool) bool {
+	if c.writable() != nil {
+		return false
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[k]
+	if !ok || s.pending(k) || c.expired(item) || item.pinned == on {
+		return on && ok && item.pinned
+	}
+	item.pinned = on
+	s.items[k] = item
+	s.publish(k, item)
+	if on {
+		atomic.AddInt64(&c.pinned, 1)
+	} else {
+		atomic.AddInt64(&c.pinned, -1)
+		// 固定期间到期的位置已被丢弃
+		s.trackExpiration(k, item.Expiration)
+	}
+	return true
+}
+
+func (c *Cache) IsPinned(k string) bool {
+	s := c.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.items[k].pinned
+}
@@ -0,0 +1,146 @@
+package fcache
+
+import "container/list"
+
+type EvictionPolicy int
+
+const (
+	// 淘汰最久未访问的key
+	LRU EvictionPolicy = iota
+	// 淘汰访问次数最少的key, 次数相同时淘汰最久未访问的
+	LFU
+	// 只访问过一次的key进入先进先出队列并优先淘汰, 再次访问后进入LRU队列
+	// 周期性的全量扫描不会挤掉热点key
+	TwoQueue
+)
+
+// 记录key的访问情况并给出淘汰顺序, 调用时需持有shard的写锁
+type evictionList interface {
+	touch(k string)
+	remove(k string)
+	// 按淘汰顺序遍历, f返回false时停止; f中可以删除当前key
+	eachOldest(f func(k string) bool)
+}
+
+func newEvictionList(p EvictionPolicy) evictionList {
+	switch p {
+	case LFU:
+		return newLFUList()
+	case TwoQueue:
+		return newTwoQueue()
+	}
+	return newLRUList()
+}
+
+type lfuBucket struct {
+	freq int
+	keys *list.List
+}
+
+type lfuEntry struct {
+	bucket *list.Element
+	elem   *list.Element
+}
+
+// 按访问次数从小到大排列的桶, 每个桶内队头为最近访问
+type lfuList struct {
+	buckets *list.List
+	elems   map[string]*lfuEntry
+}
+
+func newLFUList() *lfuList {
+	return &lfuList{
+		buckets: list.New(),
+		elems:   map[string]*lfuEntry{},
+	}
+}
+
+func (l *lfuList) touch(k string) {
+	e, ok := l.elems[k]
+	if !ok {
+		front := l.buckets.Front()
+		if front == nil || front.Value.(*lfuBucket).freq != 1 {
+			front = l.buckets.PushFront(&lfuBucket{freq: 1, keys: list.New()})
+		}
+		l.elems[k] = &lfuEntry{bucket: front, elem: front.Value.(*lfuBucket).keys.PushFront(k)}
+		return
+	}
+	cur := e.bucket.Value.(*lfuBucket)
+	next := e.bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != cur.freq+1 {
+		next = l.buckets.InsertAfter(&lfuBucket{freq: cur.freq + 1, keys: list.New()}, e.bucket)
+	}
+	cur.keys.Remove(e.elem)
+	if cur.keys.Len() == 0 {
+		l.buckets.Remove(e.bucket)
+	}
+	e.bucket = next
+	e.elem = next.Value.(*lfuBucket).keys.PushFront(k)
+}
+
+func (l *lfuList) remove(k string) {
+	e, ok := l.elems[k]
+	if !ok {
+		return
+	}
+	b := e.bucket.Value.(*lfuBucket)
+	b.keys.Remove(e.elem)
+	if b.keys.Len() == 0 {
+		l.buckets.Remove(e.bucket)
+	}
+	delete(l.elems, k)
+}
+
+func (l *lfuList) eachOldest(f func(k string) bool) {
+	for b := l.buckets.Front(); b != nil; {
+		nextBucket := b.Next()
+		keys := b.Value.(*lfuBucket).keys
+		for e := keys.Back(); e != nil; {
+			prev := e.Prev()
+			if !f(e.Value.(string)) {
+				return
+			}
+			e = prev
+		}
+		b = nextBucket
+	}
+}
+
+// 简化的2Q: in为只访问过一次的key, hot为访问过多次的key
+type twoQueue struct {
+	in  *lruList
+	hot *lruList
+}
+
+func newTwoQueue() *twoQueue {
+	return &twoQueue{in: newLRUList(), hot: newLRUList()}
+}
+
+func (q *twoQueue) touch(k string) {
+	if _, ok := q.hot.elems[k]; ok {
+		q.hot.touch(k)
+		return
+	}
+	if _, ok := q.in.elems[k]; ok {
+		q.in.remove(k)
+		q.hot.touch(k)
+		return
+	}
+	q.in.touch(k)
+}
+
+func (q *twoQueue) remove(k string) {
+	q.in.remove(k)
+	q.hot.remove(k)
+}
+
+func (q *twoQueue) eachOldest(f func(k string) bool) {
+	stopped := false
+	q.in.eachOldest(func(k string) bool {
+		stopped = !f(k)
+		return !stopped
+	})
+	if !stopped {
+		q.hot.eachOldest(f)
+	}
+}
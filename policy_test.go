@@ -0,0 +1,27 @@
+package fcache
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvictionPolicyOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EvictionPolicy
+		ops    []string
+		want   []string
+	}{
+		{"lfu least used", LFU, []string{"+a", "+b", "+c", "a", "a", "b", "+d"}, []string{"a", "b", "d"}},
+		{"lfu tie evicts oldest", LFU, []string{"+a", "+b", "+c", "+d"}, []string{"b", "c", "d"}},
+		{"lfu overwrite counts", LFU, []string{"+a", "+b", "+c", "+a", "+b", "+d", "+e"}, []string{"a", "b", "e"}},
+		{"2q evicts once-seen first", TwoQueue, []string{"+a", "+b", "+c", "a", "+d"}, []string{"a", "c", "d"}},
+		{"2q survives scan", TwoQueue, []string{"+a", "a", "+b", "+c", "+d", "+e", "+f"}, []string{"a", "e", "f"}},
+		{"2q hot in lru order", TwoQueue, []string{"+a", "a", "+b", "b", "+c", "c", "a", "+d", "+e"}, []string{"a", "c", "e"}},
+	}
+	for _, tt := range tests {
+		if got := runEviction(tt.policy, 3, tt.ops); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keys = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
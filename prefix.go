@@ -0,0 +1,112 @@
+package fcache
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// 对以Prefix开头的key生效的规则, 多条规则匹配时使用前缀最长的一条; 0值的字段不限制
+type PrefixPolicy struct {
+	Prefix string
+	// 写入时传入DefaultExpiration使用的过期时间, 覆盖缓存的默认过期时间
+	DefaultTTL time.Duration
+	// 该前缀下的条目数, 估算内存占用和开销的上限, 超出时淘汰该前缀下最久未访问的key
+	MaxEntries int
+	MaxMemory  int64
+	MaxCost    int64
+}
+
+// 同一个缓存内按key前缀使用不同的默认过期时间和容量上限, 各前缀的上限独立于缓存整体的上限
+func WithPrefixPolicy(p PrefixPolicy) Option {
+	return func(o *options) { o.prefixes = append(o.prefixes, p) }
+}
+
+type prefixRule struct {
+	PrefixPolicy
+	count int64
+	mem   int64
+	cost  int64
+	// 下一次从哪个shard开始淘汰
+	next uint32
+}
+
+// 返回按前缀长度从长到短排列的规则
+func newPrefixRules(ps []PrefixPolicy) []*prefixRule {
+	rules := make([]*prefixRule, len(ps))
+	for i, p := range ps {
+		rules[i] = &prefixRule{PrefixPolicy: p}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].Prefix) > len(rules[j].Prefix) })
+	return rules
+}
+
+// 没有匹配的规则时返回nil
+func (c *Cache) ruleFor(k string) *prefixRule {
+	for _, r := range c.prefixes {
+		if strings.HasPrefix(k, r.Prefix) {
+			return r
+		}
+	}
+	return nil
+}
+
+// 调用时需持有写锁, n为条目数的变化
+func (c *Cache) account(k string, n int, mem, cost int64) {
+	if len(c.prefixes) == 0 {
+		return
+	}
+	if r := c.ruleFor(k); r != nil {
+		atomic.AddInt64(&r.count, int64(n))
+		atomic.AddInt64(&r.mem, mem)
+		atomic.AddInt64(&r.cost, cost)
+	}
+}
+
+func (r *prefixRule) over() bool {
+	return (r.MaxEntries > 0 && atomic.LoadInt64(&r.count) > int64(r.MaxEntries)) ||
+		(r.MaxMemory > 0 && atomic.LoadInt64(&r.mem) > r.MaxMemory) ||
+		(r.MaxCost > 0 && atomic.LoadInt64(&r.cost) > r.MaxCost)
+}
+
+// 在锁外将各前缀收缩到上限以内
+func (c *Cache) shrinkPrefixes() {
+	for _, r := range c.prefixes {
+		for r.over() && c.evictPrefix(r) {
+		}
+	}
+}
+
+// 从各shard轮流淘汰一个属于r的未被引用也没有被固定的key, 调用时不能持有任何shard的锁
+func (c *Cache) evictPrefix(r *prefixRule) bool {
+	start := int(atomic.AddUint32(&r.next, 1))
+	for i := range c.shards {
+		s := c.shards[(start+i)%len(c.shards)]
+		s.mu.Lock()
+		evicted := false
+		s.policy.eachOldest(func(k string) bool {
+			if s.refs[k] > 0 || s.items[k].pinned || c.ruleFor(k) != r {
+				return true
+			}
+			evicted = s.delete(k, Evicted)
+			return !evicted
+		})
+		s.unlock()
+		if evicted {
+			atomic.AddUint64(&c.stats.evictions, 1)
+			return true
+		}
+	}
+	return false
+}
+
+// 返回前缀规则下当前的条目数, 估算内存占用和开销, 没有该规则时返回false
+func (c *Cache) PrefixUsage(prefix string) (entries int, memory, cost int64, ok bool) {
+	for _, r := range c.prefixes {
+		if r.Prefix == prefix {
+			return int(atomic.LoadInt64(&r.count)), atomic.LoadInt64(&r.mem), atomic.LoadInt64(&r.cost), true
+		}
+	}
+	return 0, 0, 0, false
+}
@@ -0,0 +1,44 @@
+package fcache
+
+import (
+	"context"
+	"time"
+)
+
+// Allow在缓存中保存的令牌桶状态
+type TokenBucket struct {
+	Tokens float64
+	// 上次更新的时间
+	Updated int64
+}
+
+// 按令牌桶限流, 每个key的桶容量为limit, 每window补满一次; 返回true时消耗一个令牌
+// 桶在window内没有请求时自动过期, 之后的请求按满桶计算; 缓存关闭, 只读或因容量上限无法写入时返回false
+func (c *Cache) Allow(k string, limit int, window time.Duration) bool {
+	if c.writable() != nil || limit <= 0 || window <= 0 {
+		return false
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	defer c.shrink()
+	defer s.unlock()
+	now := c.nowNano()
+	b := TokenBucket{Tokens: float64(limit), Updated: now}
+	if item, ok := s.live(k); ok {
+		old, ok := unpack(item.Object).(TokenBucket)
+		if ok {
+			b.Tokens = old.Tokens + float64(now-old.Updated)*float64(limit)/float64(window)
+			if b.Tokens > float64(limit) {
+				b.Tokens = float64(limit)
+			}
+		}
+	} else if err := s.waitSpace(context.Background(), k); err != nil {
+		return false
+	}
+	allowed := b.Tokens >= 1
+	if allowed {
+		b.Tokens--
+	}
+	s.set(k, b, window)
+	return allowed
+}
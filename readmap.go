@@ -0,0 +1,83 @@
+package fcache
+
+import "sync/atomic"
+
+// 开启WithLockFreeReads时每个shard额外持有的只读副本, 读取时无需加锁
+// 与sync.Map相同: 已有key的修改和删除直接替换副本中的条目, 新写入的key在多次未命中后整体重建副本
+type readMap struct {
+	m map[string]*readEntry
+}
+
+// p为nil表示已删除
+type readEntry struct {
+	p atomic.Pointer[Item]
+}
+
+// 只读副本中有效的条目直接返回, 不更新访问顺序和读取次数; 返回false时由调用方加锁读取
+func (s *shard) getFast(k string) (interface{}, bool) {
+	rm := s.read.Load()
+	if rm == nil {
+		return nil, false
+	}
+	e, ok := rm.m[k]
+	if !ok {
+		return nil, false
+	}
+	item := e.p.Load()
+	// 滑动过期需要在写锁内延长过期时间
+	if item == nil || s.c.expired(*item) || s.slides(*item) {
+		return nil, false
+	}
+	return s.c.copyValue(unpack(item.Object)), true
+}
+
+// 调用时需持有写锁
+func (s *shard) publish(k string, item Item) {
+	rm := s.read.Load()
+	if rm == nil {
+		return
+	}
+	if e, ok := rm.m[k]; ok {
+		e.p.Store(&item)
+		return
+	}
+	s.readDirty = true
+}
+
+func (s *shard) unpublish(k string) {
+	rm := s.read.Load()
+	if rm == nil {
+		return
+	}
+	if e, ok := rm.m[k]; ok {
+		e.p.Store(nil)
+	}
+}
+
+// 加锁读取前调用, 未命中次数达到条目数时按当前条目重建只读副本
+func (s *shard) readMiss() {
+	if s.read.Load() == nil || !s.readDirty {
+		return
+	}
+	s.readMisses++
+	if s.readMisses < len(s.items) {
+		return
+	}
+	s.rebuildRead()
+}
+
+func (s *shard) rebuildRead() {
+	m := make(map[string]*readEntry, len(s.items))
+	for k, v := range s.items {
+		if s.pending(k) {
+			continue
+		}
+		e := &readEntry{}
+		item := v
+		e.p.Store(&item)
+		m[k] = e
+	}
+	s.read.Store(&readMap{m: m})
+	s.readMisses = 0
+	s.readDirty = false
+}
@@ -0,0 +1,88 @@
+package fcache
+
+import "time"
+
+// 写入和删除返回ErrReadOnly或不生效, 数据只能通过Load系列方法, AttachMapped和WithAutoSave等加载
+// loader的结果直接返回而不写入, 过期的条目仍会被清理
+func WithReadOnly() Option {
+	return func(o *options) { o.readOnly = true }
+}
+
+func (c *Cache) SetReadOnly(on bool) {
+	c.configure(func(cfg *config) { cfg.readOnly = on })
+}
+
+func (c *Cache) readOnly() bool {
+	return c.conf().readOnly
+}
+
+// 写操作开始前检查
+func (c *Cache) writable() error {
+	if c.closed() {
+		return ErrClosed
+	}
+	if c.readOnly() {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// 某一时刻缓存内容的不可变视图, 之后对缓存的修改不会反映到视图中
+// 读取不加锁也不计入统计, 条目仍按各自的过期时间过期
+type Frozen struct {
+	c     *Cache
+	items map[string]Item
+}
+
+// 复制当前未过期的条目, 逐个shard在读锁下复制, 不是严格的同一时刻
+func (c *Cache) Freeze() *Frozen {
+	return &Frozen{c: c, items: c.Items()}
+}
+
+func (f *Frozen) Get(k string) (interface{}, bool) {
+	item, ok := f.items[k]
+	if !ok || f.c.expired(item) {
+		return nil, false
+	}
+	return f.c.copyValue(item.Object), true
+}
+
+// 没有过期时间时返回零值time.Time
+func (f *Frozen) GetWithExpiration(k string) (interface{}, time.Time, bool) {
+	item, ok := f.items[k]
+	if !ok || f.c.expired(item) {
+		return nil, time.Time{}, false
+	}
+	var exp time.Time
+	if item.Expiration > 0 {
+		exp = time.Unix(0, item.Expiration)
+	}
+	return f.c.copyValue(item.Object), exp, true
+}
+
+// 包括冻结之后已过期的条目
+func (f *Frozen) Count() int {
+	return len(f.items)
+}
+
+func (f *Frozen) Keys() []string {
+	keys := make([]string, 0, len(f.items))
+	for k, item := range f.items {
+		if !f.c.expired(item) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// 遍历未过期的条目, f返回false时停止
+func (f *Frozen) Range(fn func(k string, v interface{}) bool) {
+	for k, item := range f.items {
+		if f.c.expired(item) {
+			continue
+		}
+		if !fn(k, f.c.copyValue(item.Object)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package fcache
+
+// 条目被移除的原因
+type Reason int
+
+const (
+	// 过期后被清理
+	Expired Reason = iota
+	// 超出容量被淘汰
+	Evicted
+	// 被显式删除
+	Deleted
+	// 被新值覆盖
+	Replaced
+	// 被Flush清空
+	Flushed
+	// 内存紧张时移除的soft条目, 见WithSoftMemoryLimit
+	Shed
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Expired:
+		return "expired"
+	case Evicted:
+		return "evicted"
+	case Deleted:
+		return "deleted"
+	case Replaced:
+		return "replaced"
+	case Flushed:
+		return "flushed"
+	case Shed:
+		return "shed"
+	}
+	return "unknown"
+}
+
+type removal struct {
+	Entry
+	reason Reason
+}
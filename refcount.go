@@ -0,0 +1,57 @@
+package fcache
+
+import "sync"
+
+// 返回[]byte值本身而不拷贝, 调用release之前该key不会被删除或过期清理
+// key不存在或值不是[]byte时返回nil和一个空的release
+func (c *Cache) GetRef(k string) ([]byte, func()) {
+	s := c.shard(k)
+	s.mu.Lock()
+	defer s.unlock()
+	v, ok := s.get(k)
+	c.recordGet(ok)
+	if !ok {
+		return nil, func() {}
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, func() {}
+	}
+	if s.refs == nil {
+		s.refs = map[string]int{}
+		s.pendingDelete = map[string]Reason{}
+	}
+	s.refs[k]++
+	var once sync.Once
+	return b, func() {
+		once.Do(func() { s.release(k) })
+	}
+}
+
+func (s *shard) release(k string) {
+	s.mu.Lock()
+	defer s.unlock()
+	s.refs[k]--
+	if s.refs[k] > 0 {
+		return
+	}
+	delete(s.refs, k)
+	if reason, ok := s.pendingDelete[k]; ok {
+		delete(s.pendingDelete, k)
+		s.delete(k, reason)
+	}
+}
+
+// 被引用的key延迟到最后一次release时删除
+func (s *shard) deferDelete(k string, reason Reason) bool {
+	if s.refs[k] == 0 {
+		return false
+	}
+	s.pendingDelete[k] = reason
+	return true
+}
+
+func (s *shard) pending(k string) bool {
+	_, ok := s.pendingDelete[k]
+	return ok
+}
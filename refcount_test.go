@@ -0,0 +1,101 @@
+package fcache
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetRefDefersDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		remove func(c *Cache, clock *FakeClock)
+	}{
+		{"delete", func(c *Cache, clock *FakeClock) { c.Delete("blob") }},
+		{"flush", func(c *Cache, clock *FakeClock) { c.Flush() }},
+		{"expire", func(c *Cache, clock *FakeClock) {
+			c.Expire("blob", time.Second)
+			clock.Advance(2 * time.Second)
+			c.RunGC()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Unix(1000, 0))
+			c := New(WithClock(clock), WithGCInterval(0))
+			want := []byte("payload")
+			c.Set("blob", append([]byte(nil), want...), NoExpiration)
+
+			b1, release1 := c.GetRef("blob")
+			b2, release2 := c.GetRef("blob")
+			if !bytes.Equal(b1, want) || &b1[0] != &b2[0] {
+				t.Fatalf("GetRef = %q, %q; want the same stored slice", b1, b2)
+			}
+			tt.remove(c, clock)
+			if _, ok := c.Get("blob"); ok {
+				t.Fatal("removed key still readable")
+			}
+			release1()
+			release1()
+			if n := c.Count(); n != 1 {
+				t.Fatalf("Count = %d with a ref outstanding, want 1", n)
+			}
+			if !bytes.Equal(b2, want) {
+				t.Fatalf("bytes changed while referenced: %q", b2)
+			}
+			release2()
+			if n := c.Count(); n != 0 {
+				t.Fatalf("Count = %d after release, want 0", n)
+			}
+		})
+	}
+
+	c := New(WithGCInterval(0))
+	c.Set("str", "not bytes", NoExpiration)
+	for _, k := range []string{"str", "missing"} {
+		if b, release := c.GetRef(k); b != nil || release == nil {
+			t.Errorf("GetRef(%s) = %q", k, b)
+		} else {
+			release()
+		}
+	}
+}
+
+// Flush保留被引用的key时不发出写入事件, 也不在flush记录之后写入append log
+func TestFlushReferencedKeyNoSideEffects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aof")
+	c := New(WithGCInterval(0))
+	if err := c.EnableAppendLog(path, SyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	c.Set("blob", []byte("payload"), NoExpiration)
+	events, cancel, err := c.Subscribe("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, release := c.GetRef("blob")
+	c.Flush()
+	release()
+	cancel()
+	var got []EventType
+	for e := range events {
+		got = append(got, e.Type)
+	}
+	if want := []EventType{EventDelete}; !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := New(WithGCInterval(0))
+	if err := replayed.EnableAppendLog(path, SyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.Close()
+	if _, ok := replayed.Get("blob"); ok {
+		t.Fatal("flushed key came back after replay")
+	}
+}
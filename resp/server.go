@@ -0,0 +1,513 @@
+// Redis RESP协议服务, 支持常用的字符串命令, 便于redis-cli和Redis客户端库直接访问Cache
+//
+// 支持的命令: PING ECHO GET SET(EX/PX/NX/XX) DEL EXISTS EXPIRE PEXPIRE TTL PTTL PERSIST
+// INCR INCRBY DECR DECRBY KEYS DBSIZE FLUSHALL FLUSHDB QUIT
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fredalxin/fcache"
+)
+
+const (
+	// 单个参数的大小上限, 与Redis默认的proto-max-bulk-len一致
+	maxBulk = 512 << 20
+	// 单个命令的参数个数上限
+	maxArgs = 1 << 20
+	// 单行的长度上限, 超过时关闭连接
+	maxLine = 64 << 10
+	// 声明的参数长度不超过bulkChunk时才预先分配, 更长的参数随实际收到的数据增长
+	bulkChunk = 64 << 10
+	// INCR等命令与并发写入冲突时的最大重试次数
+	maxIncrRetries = 100
+)
+
+type Server struct {
+	cache *fcache.Cache
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+func New(c *fcache.Cache) *Server {
+	return &Server{
+		cache:     c,
+		listeners: map[net.Listener]struct{}{},
+		conns:     map[net.Conn]struct{}{},
+	}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// 阻塞直到l出错或Close被调用
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return fcache.ErrClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// 关闭所有监听和连接, 不关闭底层的Cache
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	r := bufio.NewReaderSize(conn, maxLine)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err != io.EOF {
+				writeError(w, "ERR Protocol error: "+err.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.handle(w, args)
+		// 管道中的后续命令已在缓冲区时延后flush
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+var (
+	errProtocol    = errors.New("invalid request")
+	errLineTooLong = errors.New("line too long")
+)
+
+// 读取一条命令, 支持RESP数组和空格分隔的内联命令, *-1和*0视为空命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArgs {
+		return nil, errProtocol
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	args := make([]string, 0, 8)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulk {
+			return nil, errProtocol
+		}
+		arg, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// 读取size字节和结尾的\r\n, 缓冲随实际收到的数据增长
+func readBulk(r *bufio.Reader, size int) (string, error) {
+	var buf bytes.Buffer
+	if size < bulkChunk {
+		buf.Grow(size + 2)
+	}
+	if _, err := io.CopyN(&buf, r, int64(size)+2); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	b := buf.Bytes()
+	if b[size] != '\r' || b[size+1] != '\n' {
+		return "", errProtocol
+	}
+	return string(b[:size]), nil
+}
+
+// 超过r的缓冲大小的行返回错误
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", errLineTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-" + msg + "\r\n")
+}
+
+func writeSimple(w *bufio.Writer, msg string) {
+	w.WriteString("+" + msg + "\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	if b == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func writeArray(w *bufio.Writer, items []string) {
+	w.WriteString("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, s := range items {
+		writeBulk(w, []byte(s))
+	}
+}
+
+// 非RESP写入的值: string原样返回, 其他类型以fmt格式化
+func encode(v interface{}) []byte {
+	switch x := v.(type) {
+	case []byte:
+		return x
+	case string:
+		return []byte(x)
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+func wrongArgs(w *bufio.Writer, cmd string) {
+	writeError(w, "ERR wrong number of arguments for '"+strings.ToLower(cmd)+"' command")
+}
+
+// 返回true表示客户端请求关闭连接
+func (s *Server) handle(w *bufio.Writer, args []string) bool {
+	cmd := strings.ToUpper(args[0])
+	args = args[1:]
+	switch cmd {
+	case "PING":
+		if len(args) > 0 {
+			writeBulk(w, []byte(args[0]))
+		} else {
+			writeSimple(w, "PONG")
+		}
+	case "ECHO":
+		if len(args) != 1 {
+			wrongArgs(w, cmd)
+			break
+		}
+		writeBulk(w, []byte(args[0]))
+	case "QUIT":
+		writeSimple(w, "OK")
+		return true
+	case "COMMAND":
+		// redis-cli启动时会发送COMMAND DOCS
+		w.WriteString("*0\r\n")
+	case "GET":
+		if len(args) != 1 {
+			wrongArgs(w, cmd)
+			break
+		}
+		v, ok := s.cache.Get(args[0])
+		if !ok {
+			writeBulk(w, nil)
+			break
+		}
+		writeBulk(w, encode(v))
+	case "SET":
+		s.set(w, args)
+	case "DEL":
+		if len(args) == 0 {
+			wrongArgs(w, cmd)
+			break
+		}
+		var n int64
+		for _, k := range args {
+			if s.cache.Remove(k) {
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "EXISTS":
+		if len(args) == 0 {
+			wrongArgs(w, cmd)
+			break
+		}
+		var n int64
+		for _, k := range args {
+			if s.cache.Exists(k) {
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "EXPIRE", "PEXPIRE":
+		if len(args) != 2 {
+			wrongArgs(w, cmd)
+			break
+		}
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			break
+		}
+		unit := time.Second
+		if cmd == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		if n <= 0 {
+			// 非正数的过期时间表示立即删除
+			writeInt(w, boolInt(s.cache.Remove(args[0])))
+			break
+		}
+		writeInt(w, boolInt(s.cache.Expire(args[0], time.Duration(n)*unit)))
+	case "TTL", "PTTL":
+		if len(args) != 1 {
+			wrongArgs(w, cmd)
+			break
+		}
+		left, ok := s.cache.TTL(args[0])
+		switch {
+		case !ok:
+			writeInt(w, -2)
+		case left == fcache.NoExpiration:
+			writeInt(w, -1)
+		case cmd == "TTL":
+			writeInt(w, int64((left+time.Second/2)/time.Second))
+		default:
+			writeInt(w, int64(left/time.Millisecond))
+		}
+	case "PERSIST":
+		if len(args) != 1 {
+			wrongArgs(w, cmd)
+			break
+		}
+		left, ok := s.cache.TTL(args[0])
+		writeInt(w, boolInt(ok && left != fcache.NoExpiration && s.cache.Persist(args[0])))
+	case "INCR", "DECR", "INCRBY", "DECRBY":
+		want := 1
+		if strings.HasSuffix(cmd, "BY") {
+			want = 2
+		}
+		if len(args) != want {
+			wrongArgs(w, cmd)
+			break
+		}
+		delta := int64(1)
+		if want == 2 {
+			var err error
+			if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				writeError(w, "ERR value is not an integer or out of range")
+				break
+			}
+		}
+		if strings.HasPrefix(cmd, "DECR") {
+			delta = -delta
+		}
+		n, err := s.incr(args[0], delta)
+		if err != nil {
+			writeError(w, err.Error())
+			break
+		}
+		writeInt(w, n)
+	case "KEYS":
+		if len(args) != 1 {
+			wrongArgs(w, cmd)
+			break
+		}
+		keys, err := s.cache.KeysByPattern(args[0])
+		if err != nil {
+			writeError(w, "ERR "+err.Error())
+			break
+		}
+		writeArray(w, keys)
+	case "DBSIZE":
+		writeInt(w, int64(s.cache.Count()))
+	case "FLUSHALL", "FLUSHDB":
+		s.cache.Flush()
+		writeSimple(w, "OK")
+	default:
+		writeError(w, "ERR unknown command '"+strings.ToLower(cmd)+"'")
+	}
+	return false
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// SET key value [EX seconds|PX milliseconds] [NX|XX]
+func (s *Server) set(w *bufio.Writer, args []string) {
+	if len(args) < 2 {
+		wrongArgs(w, "SET")
+		return
+	}
+	k, v := args[0], []byte(args[1])
+	d := fcache.NoExpiration
+	var nx, xx bool
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i]); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 == len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+			i++
+			n, err := strconv.ParseInt(args[i], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			d = time.Duration(n) * unit
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+	switch {
+	case nx && xx:
+		writeError(w, "ERR syntax error")
+	case nx:
+		if s.cache.Add(k, v, d) != nil {
+			writeBulk(w, nil)
+			return
+		}
+		writeSimple(w, "OK")
+	case xx:
+		if s.cache.Update(k, v, d) != nil {
+			writeBulk(w, nil)
+			return
+		}
+		writeSimple(w, "OK")
+	default:
+		if err := s.cache.SetCtx(context.Background(), k, v, d); err != nil {
+			writeError(w, "ERR "+err.Error())
+			return
+		}
+		writeSimple(w, "OK")
+	}
+}
+
+// 与Redis相同, 不存在的key视为0, 保留原有的过期时间
+func (s *Server) incr(k string, delta int64) (int64, error) {
+	for i := 0; i < maxIncrRetries; i++ {
+		v, version, found := s.cache.GetWithVersion(k)
+		if !found {
+			err := s.cache.Add(k, []byte(strconv.FormatInt(delta, 10)), fcache.NoExpiration)
+			if err == nil {
+				return delta, nil
+			}
+			// 并发写入时重试, 其他原因的失败直接返回
+			if !errors.Is(err, fcache.ErrKeyExists) {
+				return 0, errors.New("ERR " + err.Error())
+			}
+			continue
+		}
+		n, err := strconv.ParseInt(string(encode(v)), 10, 64)
+		if err != nil {
+			return 0, errors.New("ERR value is not an integer or out of range")
+		}
+		if (delta > 0 && n > n+delta) || (delta < 0 && n < n+delta) {
+			return 0, errors.New("ERR increment or decrement would overflow")
+		}
+		n += delta
+		d := fcache.NoExpiration
+		if left, ok := s.cache.TTL(k); ok && left != fcache.NoExpiration {
+			if left <= 0 {
+				continue
+			}
+			d = left
+		}
+		err = s.cache.SetIfVersionErr(k, []byte(strconv.FormatInt(n, 10)), version, d)
+		if err == nil {
+			return n, nil
+		}
+		if !errors.Is(err, fcache.ErrVersionMismatch) && !errors.Is(err, fcache.ErrKeyNotFound) {
+			return 0, errors.New("ERR " + err.Error())
+		}
+	}
+	return 0, errors.New("ERR too many concurrent writes to " + k)
+}
@@ -0,0 +1,81 @@
+package resp
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fredalxin/fcache"
+)
+
+// 发送in和QUIT, 返回连接关闭前收到的全部回复
+func roundTrip(t *testing.T, s *Server, in string) string {
+	client, server := net.Pipe()
+	go s.serveConn(server)
+	go io.WriteString(client, in+"QUIT\r\n")
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	out, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("read %q: %v", in, err)
+	}
+	return string(out)
+}
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"inline", "PING\r\n", "+PONG\r\n+OK\r\n"},
+		{"array", "*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n", "$2\r\nhi\r\n+OK\r\n"},
+		{"null array", "*-1\r\nPING\r\n", "+PONG\r\n+OK\r\n"},
+		{"empty array", "*0\r\nPING\r\n", "+PONG\r\n+OK\r\n"},
+		{"negative count", "*-2\r\nPING\r\n", "+PONG\r\n+OK\r\n"},
+		{"too many args", "*99999999\r\n", "-ERR Protocol error: invalid request\r\n"},
+		{"bad count", "*x\r\n", "-ERR Protocol error: invalid request\r\n"},
+		{"negative bulk", "*1\r\n$-1\r\n", "-ERR Protocol error: invalid request\r\n"},
+		{"oversized bulk", "*1\r\n$9999999999\r\n", "-ERR Protocol error: invalid request\r\n"},
+		{"missing bulk", "*1\r\nPING\r\n", "-ERR Protocol error: invalid request\r\n"},
+		{"bad terminator", "*1\r\n$4\r\nPINGxx", "-ERR Protocol error: invalid request\r\n"},
+		{"line too long", strings.Repeat("a", maxLine+1), "-ERR Protocol error: line too long\r\n"},
+	}
+	for _, tt := range tests {
+		s := New(fcache.New(fcache.WithGCInterval(0)))
+		if got := roundTrip(t, s, tt.in); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// DEL和EXISTS不调用loader
+func TestDelExistsSkipLoader(t *testing.T) {
+	loads := 0
+	c := fcache.New(fcache.WithGCInterval(0), fcache.WithLoader(func(ctx context.Context, k string) (interface{}, time.Duration, error) {
+		loads++
+		return []byte("loaded"), time.Hour, nil
+	}))
+	c.Set("a", []byte("1"), fcache.NoExpiration)
+	s := New(c)
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"EXISTS a b\r\n", ":1\r\n"},
+		{"DEL a b\r\n", ":1\r\n"},
+		{"EXISTS a\r\n", ":0\r\n"},
+		{"DEL a\r\n", ":0\r\n"},
+		{"PEXPIRE b 0\r\n", ":0\r\n"},
+	}
+	for _, tt := range tests {
+		if got := roundTrip(t, s, tt.in); got != tt.want+"+OK\r\n" {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if loads != 0 {
+		t.Fatalf("loader called %d times", loads)
+	}
+}
@@ -0,0 +1,529 @@
+package fcache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 一个shard持有部分key及其锁, 不同shard之间互不阻塞
+type shard struct {
+	c             *Cache
+	id            int
+	mu            sync.RWMutex
+	items         map[string]Item
+	policy        evictionList
+	memUsage      int64
+	cost          int64
+	refs          map[string]int
+	pendingDelete map[string]Reason
+	filter        *bloomFilter
+	evicted       []removal
+	events        []Event
+	invalid       []string
+	exp           expHeap
+	tags          map[string]map[string]struct{}
+	indexes       map[string]map[string]map[string]struct{}
+	// 开启WithChangeLog时的删除记录
+	tombstones map[string]tombstone
+	read       atomic.Pointer[readMap]
+	readDirty  bool
+	readMisses int
+	// 从磁盘读回时不再写回磁盘
+	hydrating bool
+	// 回放append log时丢弃过期记录, 不触发删除事件和回调
+	quiet bool
+	// waitSpace已计入条目数但还未写入的key, 写入时不再计数, unlock时归还未使用的
+	reserved map[string]struct{}
+}
+
+func newShard(c *Cache) *shard {
+	s := &shard{
+		c:      c,
+		items:  map[string]Item{},
+		policy: newEvictionList(c.policy),
+	}
+	if c.lockFreeReads {
+		s.read.Store(&readMap{})
+	}
+	return s
+}
+
+func (s *shard) deleteExpired() []Entry {
+	var removed []Entry
+	now := s.c.nowNano()
+	cfg := s.c.conf()
+	s.mu.Lock()
+	expired := s.popExpired(now)
+	s.pruneTombstones(now)
+	if cfg.archive == nil {
+		for k, v := range expired {
+			if s.delete(k, Expired) {
+				removed = append(removed, Entry{Key: k, Item: v})
+			}
+		}
+		s.unlock()
+		atomic.AddUint64(&s.c.stats.expired, uint64(len(removed)))
+		return removed
+	}
+	s.unlock()
+
+	// 归档回调可能较慢, 不持有锁
+	retained := map[string]Item{}
+	for k, v := range expired {
+		err := cfg.archive(k, v.unpacked())
+		if err == nil {
+			continue
+		}
+		s.c.warn("fcache: archive failed", "key", k, "retained", cfg.archiveRetain, "err", err)
+		if cfg.archiveRetain {
+			delete(expired, k)
+			retained[k] = v
+		}
+	}
+
+	s.mu.Lock()
+	defer s.unlock()
+	for k, v := range expired {
+		// 归档期间被重新写入的key不删除
+		item, ok := s.items[k]
+		if ok && item.Expiration == v.Expiration && item.Created == v.Created && s.delete(k, Expired) {
+			removed = append(removed, Entry{Key: k, Item: v})
+		}
+	}
+	// 归档失败保留的key下次清理时重试
+	for k, v := range retained {
+		s.trackExpiration(k, v.Expiration)
+	}
+	atomic.AddUint64(&s.c.stats.expired, uint64(len(removed)))
+	return removed
+}
+
+// 显式删除k, 返回是否删除了内存中的条目; 内存中不存在时仍删除磁盘和内存映射中的条目
+func (s *shard) deleteKey(k string) bool {
+	if _, ok := s.items[k]; !ok {
+		s.forget(k)
+		return false
+	}
+	return s.delete(k, Deleted)
+}
+
+// 删除磁盘和内存映射中的k, 调用时需持有写锁
+func (s *shard) forget(k string) {
+	if d := s.c.disk; d != nil {
+		d.append(aofDelete, k, Item{})
+	}
+	if m := s.c.mapped; m != nil {
+		m.shadow(k)
+	}
+}
+
+// 返回false表示key仍被引用, 删除被延迟
+func (s *shard) delete(k string, reason Reason) bool {
+	item, ok := s.items[k]
+	if ok {
+		if a := s.c.aof.Load(); a != nil {
+			a.append(aofDelete, k, Item{})
+		}
+		// 被淘汰的条目保留在磁盘和内存映射中, 之后读取时再放回内存
+		if reason != Evicted && reason != Shed {
+			s.forget(k)
+			if reason != Expired {
+				s.tombstone(k)
+			}
+		}
+	}
+	s.unpublish(k)
+	if s.deferDelete(k, reason) {
+		return false
+	}
+	if !ok {
+		return true
+	}
+	s.memUsage -= item.size
+	atomic.AddInt64(&s.c.memUsage, -item.size)
+	s.cost -= item.cost()
+	atomic.AddInt64(&s.c.cost, -item.cost())
+	atomic.AddInt64(&s.c.count, -1)
+	s.c.account(k, -1, -item.size, -item.cost())
+	if item.pinned {
+		atomic.AddInt64(&s.c.pinned, -1)
+	}
+	delete(s.items, k)
+	s.untag(k, item.Tags)
+	s.c.deps.unlink(k, item.Deps)
+	if reason != Evicted && reason != Shed {
+		s.changed(k)
+	}
+	s.unindex(k, item.indexed)
+	s.removed(k, item, reason)
+	s.policy.remove(k)
+	s.c.notifySpace()
+	return true
+}
+
+// 记录被移除的条目, 在unlock时触发回调
+func (s *shard) removed(k string, item Item, reason Reason) {
+	if s.quiet {
+		return
+	}
+	if reason != Replaced {
+		s.event(EventDelete, k, item.Object, reason)
+	}
+	cfg := s.c.conf()
+	if cfg.onRemoved == nil && (cfg.onEvicted == nil || reason == Replaced || reason == Flushed) {
+		return
+	}
+	s.evicted = append(s.evicted, removal{Entry: Entry{Key: k, Item: item}, reason: reason})
+}
+
+// 释放写锁, 并在锁外触发锁内积累的删除回调
+func (s *shard) unlock() {
+	s.unlockLater()()
+}
+
+// 释放写锁, 返回触发锁内积累的通知, 回调和依赖删除的函数; 同时持有多个shard的锁时全部释放后再调用
+func (s *shard) unlockLater() func() {
+	evicted, events, invalid, cfg := s.evicted, s.events, s.invalid, s.c.conf()
+	s.evicted, s.events, s.invalid = nil, nil, nil
+	released := s.unreserve()
+	s.mu.Unlock()
+	if released {
+		s.c.notifySpace()
+	}
+	return func() { s.notify(evicted, events, invalid, cfg) }
+}
+
+func (s *shard) notify(evicted []removal, events []Event, invalid []string, cfg *config) {
+	s.c.watch.publish(events)
+	if len(invalid) > 0 {
+		s.c.invalidate(invalid)
+	}
+	if len(evicted) == 0 || s.c.closed() {
+		return
+	}
+	for _, e := range evicted {
+		// OnEvicted不包含覆盖和清空
+		if cfg.onEvicted != nil && e.reason != Replaced && e.reason != Flushed {
+			cfg.onEvicted(e.Key, unpack(e.Item.Object))
+		}
+		if cfg.onRemoved != nil {
+			cfg.onRemoved(e.Key, unpack(e.Item.Object), e.reason)
+		}
+	}
+}
+
+func (s *shard) full(k string) bool {
+	max := s.c.conf().maxEntries
+	if max <= 0 {
+		return false
+	}
+	if _, ok := s.items[k]; ok {
+		return false
+	}
+	return atomic.LoadInt64(&s.c.count) >= int64(max)
+}
+
+// 为新key预先计入条目数, 先加后判断, 超过上限时回退; 不同shard并发写入时条目总数也不会超过上限
+func (s *shard) reserve(k string) bool {
+	max := s.c.conf().maxEntries
+	if max <= 0 {
+		return true
+	}
+	if _, ok := s.items[k]; ok {
+		return true
+	}
+	if _, ok := s.reserved[k]; ok {
+		return true
+	}
+	if atomic.AddInt64(&s.c.count, 1) > int64(max) {
+		atomic.AddInt64(&s.c.count, -1)
+		return false
+	}
+	if s.reserved == nil {
+		s.reserved = map[string]struct{}{}
+	}
+	s.reserved[k] = struct{}{}
+	return true
+}
+
+// 归还预留后没有写入的条目数, 返回是否有归还
+func (s *shard) unreserve() bool {
+	if len(s.reserved) == 0 {
+		return false
+	}
+	atomic.AddInt64(&s.c.count, -int64(len(s.reserved)))
+	for k := range s.reserved {
+		delete(s.reserved, k)
+	}
+	return true
+}
+
+// 调用时需持有写锁, 等待期间会释放锁, 返回时仍持有写锁; 返回nil时已为k预留了空间
+func (s *shard) waitSpace(ctx context.Context, k string) error {
+	if err := s.c.writable(); err != nil {
+		return err
+	}
+	for !s.reserve(k) {
+		overflow := s.c.conf().overflow
+		if overflow == EvictOnFull {
+			if err := s.admit(k); err != nil {
+				return err
+			}
+			if s.evictOne(k) {
+				continue
+			}
+			// 当前shard没有可淘汰的key时从其他shard淘汰
+			s.unlock()
+			evicted := s.c.evictLargest(s)
+			s.mu.Lock()
+			if evicted {
+				continue
+			}
+		}
+		if overflow != BlockOnFull {
+			return fmt.Errorf("%w: %s rejected", ErrCacheFull, k)
+		}
+		space := s.c.waitSpace()
+		// 登记后再检查一次, 避免错过登记前的通知
+		if s.reserve(k) {
+			s.c.doneWaiting()
+			return nil
+		}
+		if s.c.closed() {
+			s.c.doneWaiting()
+			return ErrClosed
+		}
+		s.unlock()
+		var err error
+		select {
+		case <-space:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		s.mu.Lock()
+		s.c.doneWaiting()
+		if err != nil {
+			return err
+		}
+		if s.c.closed() {
+			return ErrClosed
+		}
+	}
+	return nil
+}
+
+// 将写入时传入的d换算为过期时间点和实际的存活时间
+func (c *Cache) expiration(d time.Duration, now time.Time) (int64, time.Duration) {
+	if d == DefaultExpiration {
+		d = c.conf().defaultExpiration
+	}
+	max := c.conf().maxTTL
+	if d <= 0 {
+		if max <= 0 {
+			return 0, 0
+		}
+		d = max
+	}
+	if j := c.conf().ttlJitter; j > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * j * float64(d))
+		if d <= 0 {
+			d = 1
+		}
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return now.Add(d).UnixNano(), d
+}
+
+// 按maxTTL和maxLifetime限制过期时间, 覆盖加载和修改过期时间等不经过expiration的路径
+func (c *Cache) limitExpiration(item *Item, now int64) {
+	cfg := c.conf()
+	if lt := cfg.maxLifetime; lt > 0 && item.Deadline == 0 {
+		base := item.Created
+		if base == 0 {
+			base = now
+		}
+		item.Deadline = base + int64(lt)
+	}
+	if max := cfg.maxTTL; max > 0 && (item.Expiration == 0 || item.Expiration > now+int64(max)) {
+		item.Expiration = now + int64(max)
+	}
+	if item.Deadline > 0 && (item.Expiration == 0 || item.Expiration > item.Deadline) {
+		item.Expiration = item.Deadline
+	}
+}
+
+func (s *shard) set(k string, v interface{}, d time.Duration) {
+	s.setTagged(k, v, d, nil)
+}
+
+func (s *shard) setTagged(k string, v interface{}, d time.Duration, tags []string) {
+	s.setItem(k, Item{Object: v, Tags: tags}, d)
+}
+
+// 按d填充过期时间, 写入时间和版本号后写入
+func (s *shard) setItem(k string, item Item, d time.Duration) {
+	now := s.c.now()
+	item.Expiration, item.TTL = s.c.expiration(d, now)
+	item.Created = now.UnixNano()
+	item.Version = s.c.nextVersion()
+	delete(s.pendingDelete, k)
+	atomic.AddUint64(&s.c.stats.sets, 1)
+	s.store(k, item)
+	s.evictMemory(k)
+}
+
+func (s *shard) slides(item Item) bool {
+	return item.TTL > 0 && (item.Sliding || s.c.conf().sliding)
+}
+
+// 写入条目并维护访问顺序, 过滤器, 条目数和内存占用
+func (s *shard) store(k string, item Item) {
+	s.c.limitExpiration(&item, s.c.nowNano())
+	s.c.observeVersion(item.Version)
+	stored := item
+	stored.Object = s.c.pack(item.Object)
+	stored.size = s.c.sizeOf(k, stored.Object)
+	stored.indexed = s.c.indexValues(unpack(item.Object))
+	if !s.hydrating && s.c.conf().changeLog > 0 {
+		stored.seq = atomic.AddUint64(&s.c.seq, 1)
+		delete(s.tombstones, k)
+	}
+	delta, cost := stored.size, stored.cost()
+	stored.pinned = false
+	n := 0
+	if old, ok := s.items[k]; ok {
+		stored.pinned = old.pinned
+		delta -= old.size
+		cost -= old.cost()
+		s.untag(k, old.Tags)
+		s.unindex(k, old.indexed)
+		s.c.deps.unlink(k, old.Deps)
+		if s.c.expired(old) {
+			s.removed(k, old, Expired)
+		} else {
+			s.removed(k, old, Replaced)
+		}
+	} else {
+		if _, ok := s.reserved[k]; ok {
+			delete(s.reserved, k)
+		} else {
+			atomic.AddInt64(&s.c.count, 1)
+		}
+		n = 1
+	}
+	s.c.account(k, n, delta, cost)
+	s.memUsage += delta
+	atomic.AddInt64(&s.c.memUsage, delta)
+	s.cost += cost
+	atomic.AddInt64(&s.c.cost, cost)
+	s.items[k] = stored
+	s.publish(k, stored)
+	s.policy.touch(k)
+	s.tag(k, item.Tags)
+	s.index(k, stored.indexed)
+	s.c.deps.link(k, item.Deps)
+	if !s.hydrating {
+		s.changed(k)
+	}
+	s.trackExpiration(k, item.Expiration)
+	s.event(EventSet, k, item.Object, 0)
+	if a := s.c.aof.Load(); a != nil {
+		a.append(aofSet, k, item)
+	}
+	if !s.hydrating {
+		if d := s.c.disk; d != nil {
+			d.append(aofSet, k, item)
+		}
+		if m := s.c.mapped; m != nil {
+			m.shadow(k)
+		}
+	}
+	if s.filter != nil {
+		s.filter.add(k)
+	}
+}
+
+// 调用时需持有写锁, 开启lazyExpire时会删除读到的过期条目
+func (s *shard) get(k string) (interface{}, bool) {
+	if adm := s.c.conf().admission; adm != nil {
+		adm.Record(k)
+	}
+	item, ok := s.items[k]
+	if !ok || s.pending(k) {
+		return nil, false
+	}
+	if s.c.expired(item) {
+		if s.c.conf().lazyExpire && s.delete(k, Expired) {
+			atomic.AddUint64(&s.c.stats.expired, 1)
+		}
+		return nil, false
+	}
+	s.policy.touch(k)
+	item.accessed = s.c.nowNano()
+	item.hits++
+	// 过期时间堆中的旧位置在出堆时再按新的过期时间放回
+	if s.slides(item) {
+		item.Expiration = item.accessed + int64(item.TTL)
+		s.c.limitExpiration(&item, item.accessed)
+		s.publish(k, item)
+	}
+	s.items[k] = item
+	return s.c.copyValue(unpack(item.Object)), true
+}
+
+// 清空shard, 仍被引用的key保留到release时删除
+func (s *shard) flush() {
+	if a := s.c.aof.Load(); a != nil && len(s.items) > 0 {
+		a.append(aofFlush, "", Item{})
+	}
+	if d := s.c.disk; d != nil {
+		d.flushShard(s.id)
+	}
+	if m := s.c.mapped; m != nil {
+		m.flushShard(s.id)
+	}
+	items := s.items
+	s.items = map[string]Item{}
+	s.policy = newEvictionList(s.c.policy)
+	s.exp = nil
+	s.tags = nil
+	s.indexes = nil
+	atomic.AddInt64(&s.c.count, -int64(len(items)))
+	atomic.AddInt64(&s.c.memUsage, -s.memUsage)
+	s.memUsage = 0
+	atomic.AddInt64(&s.c.cost, -s.cost)
+	s.cost = 0
+	for k, v := range items {
+		s.c.account(k, -1, -v.size, -v.cost())
+		if v.pinned {
+			atomic.AddInt64(&s.c.pinned, -1)
+		}
+		if s.refs[k] > 0 {
+			// 保留到release时删除; 直接放回而不经过store, 不发出事件, 也不写入append log和磁盘
+			v.pinned = false
+			s.items[k] = v
+			atomic.AddInt64(&s.c.count, 1)
+			s.c.account(k, 1, v.size, v.cost())
+			s.memUsage += v.size
+			atomic.AddInt64(&s.c.memUsage, v.size)
+			s.cost += v.cost()
+			atomic.AddInt64(&s.c.cost, v.cost())
+			s.pendingDelete[k] = Flushed
+			s.tombstone(k)
+			continue
+		}
+		s.tombstone(k)
+		s.c.deps.unlink(k, v.Deps)
+		s.changed(k)
+		s.removed(k, v, Flushed)
+	}
+	if s.read.Load() != nil {
+		s.rebuildRead()
+	}
+}
@@ -0,0 +1,173 @@
+package fcache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   OverflowPolicy
+		wantErr  error
+		wantKeys []string
+	}{
+		{"evict", EvictOnFull, nil, []string{"b", "c", "d"}},
+		{"reject", RejectOnFull, ErrCacheFull, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(WithShards(1), WithMaxEntries(3), WithOverflowPolicy(tt.policy), WithGCInterval(0))
+			for _, k := range []string{"a", "b", "c"} {
+				if err := c.SetCtx(context.Background(), k, k, NoExpiration); err != nil {
+					t.Fatalf("Set %s: %v", k, err)
+				}
+			}
+			err := c.SetCtx(context.Background(), "d", "d", NoExpiration)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Set d: got %v, want %v", err, tt.wantErr)
+			}
+			if n := c.Count(); n != 3 {
+				t.Fatalf("Count = %d, want 3", n)
+			}
+			for _, k := range tt.wantKeys {
+				if _, ok := c.Get(k); !ok {
+					t.Errorf("%s missing", k)
+				}
+			}
+			// 覆盖已有的key不受上限影响
+			if err := c.SetCtx(context.Background(), tt.wantKeys[0], "x", NoExpiration); err != nil {
+				t.Errorf("overwrite: %v", err)
+			}
+		})
+	}
+}
+
+func TestBlockOnFullUnblocksAfterDelete(t *testing.T) {
+	c := New(WithShards(1), WithMaxEntries(1), WithOverflowPolicy(BlockOnFull), WithGCInterval(0))
+	c.Set("a", 1, NoExpiration)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.SetCtx(context.Background(), "b", 2, NoExpiration)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Set returned before space was freed: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.Delete("a")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Set still blocked after Delete")
+	}
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Fatalf("Get b = %v, %v", v, ok)
+	}
+}
+
+func TestBlockOnFullContextCanceled(t *testing.T) {
+	c := New(WithShards(1), WithMaxEntries(1), WithOverflowPolicy(BlockOnFull), WithGCInterval(0))
+	c.Set("a", 1, NoExpiration)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.SetCtx(ctx, "b", 2, NoExpiration); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b stored after cancellation")
+	}
+	if n := c.Count(); n != 1 {
+		t.Fatalf("Count = %d, want 1", n)
+	}
+}
+
+// 多个shard并发写入新key时条目总数不超过上限
+func TestMaxEntriesAcrossShards(t *testing.T) {
+	for _, p := range []OverflowPolicy{EvictOnFull, RejectOnFull} {
+		t.Run(strconv.Itoa(int(p)), func(t *testing.T) {
+			const max = 50
+			c := New(WithShards(16), WithMaxEntries(max), WithOverflowPolicy(p), WithGCInterval(0))
+			var wg sync.WaitGroup
+			stop := make(chan struct{})
+			over := make(chan int, 1)
+			go func() {
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if n := c.Count(); n > max {
+						select {
+						case over <- n:
+						default:
+						}
+					}
+				}
+			}()
+			for w := 0; w < 8; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < 500; i++ {
+						c.SetCtx(context.Background(), strconv.Itoa(w)+":"+strconv.Itoa(i), i, NoExpiration)
+					}
+				}(w)
+			}
+			wg.Wait()
+			close(stop)
+			select {
+			case n := <-over:
+				t.Fatalf("Count reached %d, max %d", n, max)
+			default:
+			}
+			if n, keys := c.Count(), len(c.Keys()); n > max || n != keys {
+				t.Fatalf("Count = %d, keys = %d, max %d", n, keys, max)
+			}
+		})
+	}
+}
+
+// 返回n个落在第i个shard上的key
+func keysInShard(c *Cache, i, n int) []string {
+	var keys []string
+	for j := 0; len(keys) < n; j++ {
+		if k := "k" + strconv.Itoa(j); c.shardIndex(k) == i {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// 新key所在的shard没有可淘汰的条目时从条目最多的shard淘汰
+func TestMaxEntriesUnevenShards(t *testing.T) {
+	const max = 10
+	c := New(WithShards(4), WithMaxEntries(max), WithGCInterval(0))
+	crowded := keysInShard(c, 0, max)
+	for _, k := range crowded {
+		c.Set(k, k, NoExpiration)
+	}
+	c.Set(keysInShard(c, 1, 1)[0], 1, NoExpiration)
+	c.Set(keysInShard(c, 2, 1)[0], 1, NoExpiration)
+	if n := c.Count(); n != max {
+		t.Fatalf("Count = %d, want %d", n, max)
+	}
+	for i, k := range crowded[:2] {
+		if _, ok := c.Get(k); ok {
+			t.Errorf("oldest key %d of the crowded shard not evicted", i)
+		}
+	}
+	if s := c.shards[0]; len(s.items) != max-2 {
+		t.Errorf("crowded shard has %d items, want %d", len(s.items), max-2)
+	}
+}
@@ -0,0 +1,135 @@
+package fcache
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// 设置了内存上限或sizeFunc, 或开启了内存统计时才估算条目大小, 否则写入时跳过反射遍历
+func (c *Cache) measuring() bool {
+	cfg := c.conf()
+	return cfg.maxMemory > 0 || cfg.sizeFunc != nil || cfg.trackMemory || c.prefixMemory
+}
+
+// 估算条目占用的内存, 优先使用自定义的sizeFunc; 不需要估算时返回0
+func (c *Cache) sizeOf(k string, v interface{}) int64 {
+	if !c.measuring() {
+		return 0
+	}
+	// 压缩的值按压缩后的长度计算
+	if p, ok := v.(packedValue); ok {
+		return int64(len(k) + len(p.data))
+	}
+	if f := c.conf().sizeFunc; f != nil {
+		return f(k, v)
+	}
+	return int64(len(k)) + estimateSize(reflect.ValueOf(v), 0)
+}
+
+// 递归深度上限, 避免环形引用
+const maxSizeDepth = 8
+
+// 基于反射的粗略估算, 不处理共享引用
+func estimateSize(v reflect.Value, depth int) int64 {
+	if !v.IsValid() {
+		return 0
+	}
+	size := int64(v.Type().Size())
+	if depth >= maxSizeDepth {
+		return size
+	}
+	switch v.Kind() {
+	case reflect.String:
+		size += int64(v.Len())
+	case reflect.Slice:
+		if v.Len() == 0 {
+			break
+		}
+		elem := v.Type().Elem()
+		if isFlat(elem.Kind()) {
+			size += int64(v.Len()) * int64(elem.Size())
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			size += estimateSize(v.Index(i), depth+1)
+		}
+	case reflect.Array:
+		if isFlat(v.Type().Elem().Kind()) {
+			break
+		}
+		size = 0
+		for i := 0; i < v.Len(); i++ {
+			size += estimateSize(v.Index(i), depth+1)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			size += estimateSize(iter.Key(), depth+1) + estimateSize(iter.Value(), depth+1)
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			size += estimateSize(v.Elem(), depth+1)
+		}
+	case reflect.Struct:
+		size = 0
+		for i := 0; i < v.NumField(); i++ {
+			size += estimateSize(v.Field(i), depth+1)
+		}
+	}
+	return size
+}
+
+func isFlat(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+// 设置内存上限(字节), 超出时按最久未访问淘汰, 小于等于0表示不限制
+func (c *Cache) SetMaxMemory(n int64) {
+	c.configure(func(cfg *config) { cfg.maxMemory = n })
+	c.remeasure()
+	c.shrink()
+}
+
+// 自定义条目大小的计算方式, 只影响之后写入的条目和尚未估算过的条目
+func (c *Cache) SetSizeFunc(f func(k string, v interface{}) int64) {
+	c.configure(func(cfg *config) { cfg.sizeFunc = f })
+	c.remeasure()
+}
+
+// 没有内存上限时是否仍估算条目大小, 用于MemoryUsage, LargestKeys等统计; RegisterExpvar, metrics和httpserver.DebugHandler会自动开启
+func (c *Cache) SetMemoryTracking(on bool) {
+	c.configure(func(cfg *config) { cfg.trackMemory = on })
+	c.remeasure()
+}
+
+// 开始估算时补上之前写入时跳过估算的条目
+func (c *Cache) remeasure() {
+	if !c.measuring() {
+		return
+	}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for k, v := range s.items {
+			if v.size != 0 {
+				continue
+			}
+			v.size = c.sizeOf(k, v.Object)
+			s.items[k] = v
+			s.memUsage += v.size
+			atomic.AddInt64(&c.memUsage, v.size)
+			c.account(k, 0, v.size, 0)
+		}
+		s.unlock()
+	}
+}
+
+// 返回估算的内存占用(字节), 没有内存上限也没有开启内存统计时为0
+func (c *Cache) MemoryUsage() int64 {
+	return atomic.LoadInt64(&c.memUsage)
+}
@@ -0,0 +1,50 @@
+package fcache
+
+import "testing"
+
+func TestSizeOnlyMeasuredWhenNeeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		measured bool
+	}{
+		{"no limit", nil, false},
+		{"max memory", []Option{WithMaxMemory(1 << 20)}, true},
+		{"tracking", []Option{WithMemoryTracking()}, true},
+		{"size func", []Option{WithSizeFunc(func(k string, v interface{}) int64 { return 10 })}, true},
+		{"prefix memory", []Option{WithPrefixPolicy(PrefixPolicy{Prefix: "p:", MaxMemory: 1 << 20})}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(append(tt.opts, WithGCInterval(0))...)
+			c.Set("k", []string{"a", "b"}, NoExpiration)
+			if got := c.MemoryUsage() > 0; got != tt.measured {
+				t.Fatalf("MemoryUsage = %d, want measured %v", c.MemoryUsage(), tt.measured)
+			}
+		})
+	}
+}
+
+// 之后开启时补上已有条目的估算, 删除后归零
+func TestRemeasure(t *testing.T) {
+	c := New(WithGCInterval(0))
+	c.Set("a", "value", NoExpiration)
+	c.Set("b", 1, NoExpiration)
+	if n := c.MemoryUsage(); n != 0 {
+		t.Fatalf("MemoryUsage = %d before tracking", n)
+	}
+	c.SetMemoryTracking(true)
+	measured := c.MemoryUsage()
+	if measured <= 0 {
+		t.Fatalf("MemoryUsage = %d after tracking", measured)
+	}
+	c.SetMaxMemory(1 << 20)
+	if n := c.MemoryUsage(); n != measured {
+		t.Fatalf("MemoryUsage = %d, measured twice (%d)", n, measured)
+	}
+	c.Delete("a")
+	c.Delete("b")
+	if n := c.MemoryUsage(); n != 0 {
+		t.Fatalf("MemoryUsage = %d after deleting everything", n)
+	}
+}
@@ -0,0 +1,175 @@
+package fcache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// 快照格式(大端序):
+//
+//	header: magic "FCSN" | version uint16 | saved_at int64 | count uint64
+//	record: length uint32 | gob编码的snapshotRecord | crc32(IEEE) uint32
+//
+// 每条记录单独编码和校验, 损坏的记录会被跳过, 其余记录照常加载
+const (
+	snapshotMagic   = "FCSN"
+	snapshotVersion = 1
+	// 单条记录的长度上限, 超出说明长度字段已损坏
+	maxSnapshotRecord = 1 << 30
+)
+
+type snapshotHeader struct {
+	Version uint16
+	SavedAt int64
+	Count   uint64
+}
+
+type snapshotRecord struct {
+	Key  string
+	Item Item
+}
+
+func (c *Cache) SaveSnapshot(w io.Writer) (err error) {
+	strict := c.conf().strictSave
+	defer func() {
+		if x := recover(); x != nil {
+			if strict {
+				panic(x)
+			}
+			err = fmt.Errorf("Error registering item types with Gob library")
+		}
+	}()
+	items := c.Items()
+	bw := bufio.NewWriter(w)
+	if _, err = bw.WriteString(snapshotMagic); err != nil {
+		return err
+	}
+	header := snapshotHeader{
+		Version: snapshotVersion,
+		SavedAt: c.nowNano(),
+		Count:   uint64(len(items)),
+	}
+	if err = binary.Write(bw, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for k, v := range items {
+		register(v.Object)
+		buf.Reset()
+		if err = gob.NewEncoder(&buf).Encode(&snapshotRecord{Key: k, Item: v}); err != nil {
+			return err
+		}
+		if err = writeFrame(bw, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func (c *Cache) SaveSnapshotToFile(file string) error {
+	return c.saveFile(file, c.SaveSnapshot)
+}
+
+// 返回成功加载的条目数; 有记录损坏时仍加载其余记录, 并返回包装了ErrCorruptSnapshot的错误
+func (c *Cache) LoadSnapshot(r io.Reader, opts ...LoadOption) (int, error) {
+	if c.closed() {
+		return 0, ErrClosed
+	}
+	r, err := decompressed(r)
+	if err != nil {
+		return 0, err
+	}
+	items, err := readSnapshot(r)
+	c.load(items, opts...)
+	return len(items), err
+}
+
+func (c *Cache) LoadSnapshotFromFile(file string, opts ...LoadOption) (n int, err error) {
+	err = c.loadFile(file, func(r io.Reader) error {
+		n, err = c.LoadSnapshot(r, opts...)
+		return err
+	})
+	return
+}
+
+func readSnapshot(r io.Reader) (map[string]Item, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapshotMagic {
+		return nil, fmt.Errorf("%w: bad magic header", ErrIncompatibleSnapshot)
+	}
+	var header snapshotHeader
+	if err := binary.Read(br, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("%w: truncated header", ErrIncompatibleSnapshot)
+	}
+	if header.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrIncompatibleSnapshot, header.Version, snapshotVersion)
+	}
+
+	items := map[string]Item{}
+	skipped := 0
+	var read uint64
+	for ; read < header.Count; read++ {
+		payload, valid, err := readFrame(br)
+		if err != nil {
+			break
+		}
+		var rec snapshotRecord
+		if !valid || gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec) != nil {
+			skipped++
+			continue
+		}
+		items[rec.Key] = rec.Item
+	}
+	if read < header.Count {
+		return items, fmt.Errorf("%w: truncated after %d of %d records", ErrCorruptSnapshot, read, header.Count)
+	}
+	if skipped > 0 {
+		return items, fmt.Errorf("%w: %d of %d records skipped", ErrCorruptSnapshot, skipped, header.Count)
+	}
+	return items, nil
+}
+
+// 写入一帧: 长度, 内容, crc32
+func writeFrame(w io.Writer, payload []byte) error {
+	var head [4]byte
+	binary.BigEndian.PutUint32(head[:], uint32(len(payload)))
+	if _, err := w.Write(head[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(head[:], crc32.ChecksumIEEE(payload))
+	_, err := w.Write(head[:])
+	return err
+}
+
+func frameSize(payload []byte) int64 {
+	return int64(len(payload)) + 8
+}
+
+// 读取一帧, valid为false表示校验失败; 返回err时帧边界已丢失, 无法继续读取
+func readFrame(r io.Reader) (payload []byte, valid bool, err error) {
+	var head [4]byte
+	if _, err = io.ReadFull(r, head[:]); err != nil {
+		return nil, false, err
+	}
+	n := binary.BigEndian.Uint32(head[:])
+	if n > maxSnapshotRecord {
+		return nil, false, ErrCorruptSnapshot
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return nil, false, err
+	}
+	if _, err = io.ReadFull(r, head[:]); err != nil {
+		return nil, false, err
+	}
+	return payload, crc32.ChecksumIEEE(payload) == binary.BigEndian.Uint32(head[:]), nil
+}
@@ -0,0 +1,94 @@
+package fcache
+
+import (
+	"context"
+	"runtime"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// 与Set相同, 条目标记为soft, 进程堆内存超过WithSoftMemoryLimit设置的上限时优先被移除
+func (c *Cache) SetSoft(k string, v interface{}, d time.Duration) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	return c.setItem(context.Background(), k, Item{Object: v, Soft: true}, d)
+}
+
+// 每次GC结束后检查上一次GC后存活的堆内存, 超过heapBytes时按估算大小移除soft条目, 直到估算释放的内存能回到上限以内
+// 移除的原因为Shed, 会触发OnEvicted和OnRemoved; onShed不为nil时在每一轮移除后以移除的key和当时的堆内存调用
+func WithSoftMemoryLimit(heapBytes uint64, onShed func(keys []string, heap uint64)) Option {
+	return func(o *options) {
+		o.softLimit = heapBytes
+		o.onShed = onShed
+	}
+}
+
+const liveHeapMetric = "/gc/heap/live:bytes"
+
+// 以finalizer感知GC结束, 每次触发后重新登记, 缓存关闭后停止
+func (c *Cache) watchHeap() {
+	sentinel := new(int)
+	runtime.SetFinalizer(sentinel, func(*int) {
+		if c.closed() {
+			return
+		}
+		// 移除需要加锁, 不阻塞finalizer goroutine
+		if atomic.CompareAndSwapInt32(&c.shedding, 0, 1) {
+			go func() {
+				defer atomic.StoreInt32(&c.shedding, 0)
+				c.checkHeap()
+			}()
+		}
+		c.watchHeap()
+	})
+}
+
+func (c *Cache) checkHeap() {
+	cfg := c.conf()
+	sample := []metrics.Sample{{Name: liveHeapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return
+	}
+	heap := sample[0].Value.Uint64()
+	if cfg.softLimit == 0 || heap <= cfg.softLimit {
+		return
+	}
+	keys := c.shed(int64(heap - cfg.softLimit))
+	c.warn("fcache: heap over soft limit", "heap", heap, "limit", cfg.softLimit, "shed", len(keys))
+	if len(keys) > 0 && cfg.onShed != nil {
+		cfg.onShed(keys, heap)
+	}
+}
+
+// 逐个shard移除soft条目, 估算释放的内存达到want时停止, 返回移除的key
+func (c *Cache) shed(want int64) []string {
+	var keys []string
+	var freed int64
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for k, item := range s.items {
+			if freed >= want {
+				break
+			}
+			if !item.Soft || item.pinned || s.pending(k) {
+				continue
+			}
+			if s.delete(k, Shed) {
+				keys = append(keys, k)
+				freed += item.size
+			}
+		}
+		s.unlock()
+		if freed >= want {
+			break
+		}
+	}
+	atomic.AddUint64(&c.stats.evictions, uint64(len(keys)))
+	return keys
+}
@@ -0,0 +1,154 @@
+package fcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 统计数据的快照
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Sets      uint64
+	Deletes   uint64
+	Evictions uint64
+	Expired   uint64
+	// 过期清理的次数和累计耗时
+	GCRuns uint64
+	GCTime time.Duration
+	// loader的调用次数, 失败次数和累计耗时
+	Loads      uint64
+	LoadErrors uint64
+	LoadTime   time.Duration
+	// loader耗时的分布, LoadCounts[i]为耗时不超过LoadBuckets[i]的次数, 不累加, 最后一项为超过所有上界的次数
+	LoadBuckets []time.Duration
+	LoadCounts  []uint64
+	// loader panic的次数
+	LoadPanics uint64
+	// 加入已有加载而没有重复调用loader的次数
+	Coalesced uint64
+	// 当前正在加载的key数量和等待结果的调用方总数
+	InFlightLoads int
+	LoadWaiters   int
+	// 当前被Pin固定的条目数
+	Pinned int64
+}
+
+// 命中率, 没有读取时返回0
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type stats struct {
+	hits       uint64
+	misses     uint64
+	sets       uint64
+	deletes    uint64
+	evictions  uint64
+	expired    uint64
+	gcRuns     uint64
+	gcNanos    uint64
+	loads      uint64
+	loadErrs   uint64
+	loadNanos  uint64
+	loadHist   [len(loadBuckets) + 1]uint64
+	loadPanics uint64
+}
+
+// loader耗时分布的上界
+var loadBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+func (c *Cache) Stats() Stats {
+	counts := make([]uint64, len(c.stats.loadHist))
+	for i := range counts {
+		counts[i] = atomic.LoadUint64(&c.stats.loadHist[i])
+	}
+	waiters := 0
+	inFlight := c.flight.inFlight()
+	for _, n := range inFlight {
+		waiters += n
+	}
+	return Stats{
+		Hits:      atomic.LoadUint64(&c.stats.hits),
+		Misses:    atomic.LoadUint64(&c.stats.misses),
+		Sets:      atomic.LoadUint64(&c.stats.sets),
+		Deletes:   atomic.LoadUint64(&c.stats.deletes),
+		Evictions: atomic.LoadUint64(&c.stats.evictions),
+		Expired:   atomic.LoadUint64(&c.stats.expired),
+		GCRuns:    atomic.LoadUint64(&c.stats.gcRuns),
+		GCTime:    time.Duration(atomic.LoadUint64(&c.stats.gcNanos)),
+
+		Loads:         atomic.LoadUint64(&c.stats.loads),
+		LoadErrors:    atomic.LoadUint64(&c.stats.loadErrs),
+		LoadTime:      time.Duration(atomic.LoadUint64(&c.stats.loadNanos)),
+		LoadBuckets:   append([]time.Duration(nil), loadBuckets[:]...),
+		LoadCounts:    counts,
+		LoadPanics:    atomic.LoadUint64(&c.stats.loadPanics),
+		Coalesced:     atomic.LoadUint64(&c.flight.coalesced),
+		InFlightLoads: len(inFlight),
+		LoadWaiters:   waiters,
+		Pinned:        atomic.LoadInt64(&c.pinned),
+	}
+}
+
+func (c *Cache) ResetStats() {
+	atomic.StoreUint64(&c.stats.hits, 0)
+	atomic.StoreUint64(&c.stats.misses, 0)
+	atomic.StoreUint64(&c.stats.sets, 0)
+	atomic.StoreUint64(&c.stats.deletes, 0)
+	atomic.StoreUint64(&c.stats.evictions, 0)
+	atomic.StoreUint64(&c.stats.expired, 0)
+	atomic.StoreUint64(&c.stats.gcRuns, 0)
+	atomic.StoreUint64(&c.stats.gcNanos, 0)
+	atomic.StoreUint64(&c.stats.loads, 0)
+	atomic.StoreUint64(&c.stats.loadErrs, 0)
+	atomic.StoreUint64(&c.stats.loadNanos, 0)
+	atomic.StoreUint64(&c.stats.loadPanics, 0)
+	for i := range c.stats.loadHist {
+		atomic.StoreUint64(&c.stats.loadHist[i], 0)
+	}
+	atomic.StoreUint64(&c.flight.coalesced, 0)
+}
+
+// 返回加载耗时
+func (c *Cache) recordLoad(start time.Time, err error) time.Duration {
+	d := time.Since(start)
+	atomic.AddUint64(&c.stats.loads, 1)
+	atomic.AddUint64(&c.stats.loadNanos, uint64(d))
+	if err != nil {
+		atomic.AddUint64(&c.stats.loadErrs, 1)
+	}
+	i := 0
+	for i < len(loadBuckets) && d > loadBuckets[i] {
+		i++
+	}
+	atomic.AddUint64(&c.stats.loadHist[i], 1)
+	return d
+}
+
+func (c *Cache) recordGC(start time.Time) {
+	atomic.AddUint64(&c.stats.gcRuns, 1)
+	atomic.AddUint64(&c.stats.gcNanos, uint64(time.Since(start)))
+}
+
+func (c *Cache) recordGet(ok bool) {
+	if ok {
+		atomic.AddUint64(&c.stats.hits, 1)
+	} else {
+		atomic.AddUint64(&c.stats.misses, 1)
+	}
+}
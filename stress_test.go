@@ -0,0 +1,281 @@
+package fcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	stressDuration = flag.Duration("stress.duration", 2*time.Second, "how long TestStress runs")
+	stressSeed     = flag.Int64("stress.seed", 0, "random seed for TestStress, 0 uses the current time")
+)
+
+const (
+	stressWorkers = 16
+	// key空间较小以增加竞争
+	stressKeys   = 200
+	stressShards = 4
+	// 超过这个时间没有任何操作完成时视为死锁
+	stressStall = 10 * time.Second
+)
+
+type stressOp struct {
+	name string
+	f    func(c *Cache, r *rand.Rand, k string) error
+}
+
+func stressTTL(r *rand.Rand) time.Duration {
+	switch r.Intn(4) {
+	case 0:
+		return NoExpiration
+	case 1:
+		return DefaultExpiration
+	}
+	return time.Duration(1+r.Intn(50)) * time.Millisecond
+}
+
+// 返回的错误表示不应出现的结果, 预期内的错误如ErrKeyNotFound不返回
+func stressOps() []stressOp {
+	return []stressOp{
+		{"Set", func(c *Cache, r *rand.Rand, k string) error {
+			c.Set(k, r.Int63(), stressTTL(r))
+			return nil
+		}},
+		{"Get", func(c *Cache, r *rand.Rand, k string) error {
+			c.Get(k)
+			return nil
+		}},
+		{"Delete", func(c *Cache, r *rand.Rand, k string) error {
+			c.Delete(k)
+			return nil
+		}},
+		{"Add", func(c *Cache, r *rand.Rand, k string) error {
+			return stressExpect(c.Add(k, r.Int63(), stressTTL(r)), ErrKeyExists)
+		}},
+		{"Update", func(c *Cache, r *rand.Rand, k string) error {
+			return stressExpect(c.Update(k, r.Int63(), stressTTL(r)), ErrKeyNotFound)
+		}},
+		{"Increment", func(c *Cache, r *rand.Rand, k string) error {
+			_, err := c.Increment(k, 1)
+			return stressExpect(err, ErrKeyNotFound, ErrTypeMismatch)
+		}},
+		{"Inc", func(c *Cache, r *rand.Rand, k string) error {
+			return stressExpect(c.Inc(k, 1), ErrKeyNotFound, ErrTypeMismatch)
+		}},
+		{"IncrBy", func(c *Cache, r *rand.Rand, k string) error {
+			_, err := c.IncrBy(k, 1, stressTTL(r))
+			return stressExpect(err, ErrTypeMismatch)
+		}},
+		{"EnsureCounter", func(c *Cache, r *rand.Rand, k string) error {
+			_, err := c.EnsureCounter(k, stressTTL(r))
+			return err
+		}},
+		{"Remove", func(c *Cache, r *rand.Rand, k string) error {
+			c.Remove(k)
+			return nil
+		}},
+		{"Exists", func(c *Cache, r *rand.Rand, k string) error {
+			c.Exists(k)
+			return nil
+		}},
+		{"Expire", func(c *Cache, r *rand.Rand, k string) error {
+			c.Expire(k, stressTTL(r))
+			return nil
+		}},
+		{"Touch", func(c *Cache, r *rand.Rand, k string) error {
+			c.Touch(k)
+			return nil
+		}},
+		{"CompareAndSwap", func(c *Cache, r *rand.Rand, k string) error {
+			if v, ok := c.Get(k); ok {
+				c.CompareAndSwap(k, v, r.Int63(), stressTTL(r))
+			}
+			return nil
+		}},
+		{"GetAndDelete", func(c *Cache, r *rand.Rand, k string) error {
+			c.GetAndDelete(k)
+			return nil
+		}},
+		{"GetOrCompute", func(c *Cache, r *rand.Rand, k string) error {
+			_, err := c.GetOrCompute(k, stressTTL(r), func() (interface{}, error) {
+				return r.Int63(), nil
+			})
+			return err
+		}},
+		{"Pin", func(c *Cache, r *rand.Rand, k string) error {
+			if r.Intn(2) == 0 {
+				c.Pin(k)
+			} else {
+				c.Unpin(k)
+			}
+			return nil
+		}},
+		{"Tx", func(c *Cache, r *rand.Rand, k string) error {
+			other := stressKey(r)
+			return stressExpect(c.Tx(func(tx *Txn) error {
+				v, _ := tx.Get(k)
+				tx.Set(other, v, stressTTL(r))
+				tx.Delete(k)
+				return nil
+			}), ErrConflict)
+		}},
+		{"SetMulti", func(c *Cache, r *rand.Rand, k string) error {
+			return c.SetMulti(map[string]interface{}{k: r.Int63(), stressKey(r): r.Int63()}, stressTTL(r))
+		}},
+		{"DeleteMulti", func(c *Cache, r *rand.Rand, k string) error {
+			c.DeleteMulti([]string{k, stressKey(r)})
+			return nil
+		}},
+		{"DeletePattern", func(c *Cache, r *rand.Rand, k string) error {
+			_, err := c.DeletePattern(k[:len(k)-1] + "*")
+			return err
+		}},
+		{"Range", func(c *Cache, r *rand.Rand, k string) error {
+			n := 0
+			c.Range(func(k string, v interface{}) bool {
+				n++
+				return n < 20
+			})
+			return nil
+		}},
+		{"RunGC", func(c *Cache, r *rand.Rand, k string) error {
+			c.RunGC()
+			return nil
+		}},
+		{"SaveLoad", func(c *Cache, r *rand.Rand, k string) error {
+			var buf bytes.Buffer
+			if err := c.Save(&buf); err != nil {
+				return err
+			}
+			return c.Load(&buf)
+		}},
+		{"Flush", func(c *Cache, r *rand.Rand, k string) error {
+			// 清空代价较高, 降低频率
+			if r.Intn(50) == 0 {
+				c.Flush()
+			}
+			return nil
+		}},
+	}
+}
+
+func stressKey(r *rand.Rand) string {
+	return "key:" + strconv.Itoa(r.Intn(stressKeys))
+}
+
+// err为nil或属于allowed时返回nil
+func stressExpect(err error, allowed ...error) error {
+	if err == nil {
+		return nil
+	}
+	for _, a := range allowed {
+		if errors.Is(err, a) {
+			return nil
+		}
+	}
+	return err
+}
+
+// 停止所有写入后检查计数
+func stressCheck(c *Cache) error {
+	keys := len(c.Keys())
+	if n := c.Count(); n < keys || n < 0 {
+		return fmt.Errorf("Count %d but %d live keys", n, keys)
+	}
+	if m := c.MemoryUsage(); m < 0 {
+		return fmt.Errorf("MemoryUsage %d", m)
+	}
+	if p := c.Stats().Pinned; p < 0 || p > int64(c.Count()) {
+		return fmt.Errorf("Pinned %d with %d entries", p, c.Count())
+	}
+	c.Flush()
+	if n, m, cost, p := c.Count(), c.MemoryUsage(), c.TotalCost(), c.Stats().Pinned; n != 0 || m != 0 || cost != 0 || p != 0 {
+		return fmt.Errorf("After Flush: count %d, memory %d, cost %d, pinned %d", n, m, cost, p)
+	}
+	return nil
+}
+
+// 多个goroutine按随机顺序执行各种操作, 检查死锁和计数是否一致, 应在-race下运行
+// 同一个seed下每个goroutine执行的操作序列相同, 交错顺序仍取决于调度
+func TestStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping stress test in short mode")
+	}
+	seed := *stressSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("seed %d", seed)
+	c := New(
+		WithShards(stressShards),
+		WithDefaultTTL(20*time.Millisecond),
+		WithGCInterval(5*time.Millisecond),
+		WithMaxEntries(stressKeys/2),
+		WithLockFreeReads(),
+	)
+	table := stressOps()
+	counts := make([]uint64, len(table))
+	var done uint64
+	ctx, cancel := context.WithTimeout(context.Background(), *stressDuration)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for w := 0; w < stressWorkers; w++ {
+		wg.Add(1)
+		go func(r *rand.Rand) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				i := r.Intn(len(table))
+				if err := table[i].f(c, r, stressKey(r)); err != nil {
+					t.Errorf("%s: %v, seed %d", table[i].name, err, seed)
+				}
+				atomic.AddUint64(&counts[i], 1)
+				atomic.AddUint64(&done, 1)
+			}
+		}(rand.New(rand.NewSource(seed + int64(w))))
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	ticker := time.NewTicker(stressStall)
+	defer ticker.Stop()
+	last := uint64(0)
+wait:
+	for {
+		select {
+		case <-finished:
+			break wait
+		case <-ticker.C:
+			n := atomic.LoadUint64(&done)
+			if n == last {
+				pprof.Lookup("goroutine").WriteTo(os.Stderr, 2)
+				t.Fatalf("no operation completed in %v, seed %d", stressStall, seed)
+			}
+			last = n
+		}
+	}
+
+	for i, o := range table {
+		t.Logf("%-16s %d", o.name, atomic.LoadUint64(&counts[i]))
+	}
+	if err := stressCheck(c); err != nil {
+		t.Errorf("check: %v, seed %d", err, seed)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package fcache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// 与Set相同, 并附加标签, 之后可通过DeleteByTag批量删除
+// 重新写入时标签会被替换, 不带标签写入会清除原有标签
+func (c *Cache) SetWithTags(k string, v interface{}, d time.Duration, tags ...string) error {
+	if err := c.checkTTL(d); err != nil {
+		return err
+	}
+	if c.skipStore(d) {
+		return nil
+	}
+	d = c.inheritTTL(k, d)
+	s := c.shard(k)
+	s.mu.Lock()
+	if err := s.waitSpace(context.Background(), k); err != nil {
+		s.unlock()
+		return err
+	}
+	s.setTagged(k, v, d, tags)
+	s.unlock()
+	c.shrink()
+	return nil
+}
+
+// 删除带有tag标签的所有key, 返回删除的数量
+func (c *Cache) DeleteByTag(tag string) int {
+	if c.writable() != nil {
+		return 0
+	}
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for k := range s.tags[tag] {
+			if s.delete(k, Deleted) {
+				n++
+			}
+		}
+		s.unlock()
+	}
+	atomic.AddUint64(&c.stats.deletes, uint64(n))
+	return n
+}
+
+// 返回带有tag标签的未过期的key
+func (c *Cache) KeysByTag(tag string) []string {
+	var keys []string
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for k := range s.tags[tag] {
+			if item := s.items[k]; !c.expired(item) && !s.pending(k) {
+				keys = append(keys, k)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return keys
+}
+
+// 调用时需持有写锁
+func (s *shard) tag(k string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if s.tags == nil {
+		s.tags = map[string]map[string]struct{}{}
+	}
+	for _, t := range tags {
+		keys, ok := s.tags[t]
+		if !ok {
+			keys = map[string]struct{}{}
+			s.tags[t] = keys
+		}
+		keys[k] = struct{}{}
+	}
+}
+
+func (s *shard) untag(k string, tags []string) {
+	for _, t := range tags {
+		keys := s.tags[t]
+		delete(keys, k)
+		if len(keys) == 0 {
+			delete(s.tags, t)
+		}
+	}
+}
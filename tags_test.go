@@ -0,0 +1,38 @@
+package fcache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSetWithTags(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithClock(clock), WithGCInterval(0))
+	c.SetWithTags("a", 1, NoExpiration, "x", "y")
+	c.SetWithTags("b", 1, NoExpiration, "x")
+	c.SetWithTags("short", 1, time.Second, "x")
+	clock.Advance(2 * time.Second)
+
+	keys := c.KeysByTag("x")
+	sort.Strings(keys)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("KeysByTag(x) = %v, want %v", keys, want)
+	}
+	c.RunGC()
+	// 重新写入时标签被替换
+	c.Set("a", 2, NoExpiration)
+	if keys := c.KeysByTag("y"); len(keys) != 0 {
+		t.Fatalf("KeysByTag(y) = %v after untagged Set", keys)
+	}
+	if n := c.DeleteByTag("x"); n != 1 {
+		t.Fatalf("DeleteByTag = %d, want 1", n)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("b survived DeleteByTag")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a deleted by a tag it no longer has")
+	}
+}
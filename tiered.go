@@ -0,0 +1,192 @@
+package fcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 远端存储, 如Redis, memcached或数据库
+type Backend interface {
+	// key不存在时返回false和nil错误
+	Get(ctx context.Context, k string) (interface{}, bool, error)
+	Set(ctx context.Context, k string, v interface{}, d time.Duration) error
+	Delete(ctx context.Context, k string) error
+}
+
+type WriteMode int
+
+const (
+	// 先写远端, 成功后再写本地
+	WriteThrough WriteMode = iota
+	// 先写本地, 远端写入在后台按顺序异步执行
+	WriteBehind
+)
+
+type tieredOptions struct {
+	mode      WriteMode
+	queueSize int
+	localTTL  time.Duration
+	onError   func(k string, err error)
+}
+
+type TieredOption func(o *tieredOptions)
+
+// 异步写远端, queueSize为等待写入的队列长度, 队列满时写入会阻塞
+func WithWriteBehind(queueSize int) TieredOption {
+	return func(o *tieredOptions) {
+		o.mode = WriteBehind
+		o.queueSize = queueSize
+	}
+}
+
+// 从远端读到的值在本地的存活时间, 默认使用本地Cache的默认过期时间
+func WithLocalTTL(d time.Duration) TieredOption {
+	return func(o *tieredOptions) { o.localTTL = d }
+}
+
+// 异步写远端失败时调用
+func WithBackendErrorHandler(f func(k string, err error)) TieredOption {
+	return func(o *tieredOptions) { o.onError = f }
+}
+
+type tieredWrite struct {
+	k      string
+	v      interface{}
+	d      time.Duration
+	delete bool
+}
+
+// 本地Cache在前, Backend在后的两级缓存
+type TieredCache struct {
+	local   *Cache
+	backend Backend
+	opts    tieredOptions
+	flight  flightGroup
+
+	queue     chan tieredWrite
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.RWMutex
+	closed    bool
+}
+
+func NewTieredCache(local *Cache, backend Backend, opts ...TieredOption) *TieredCache {
+	o := tieredOptions{localTTL: DefaultExpiration}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t := &TieredCache{local: local, backend: backend, opts: o}
+	if o.mode == WriteBehind {
+		if o.queueSize < 1 {
+			o.queueSize = 1
+		}
+		t.queue = make(chan tieredWrite, o.queueSize)
+		t.done = make(chan struct{})
+		go t.writeLoop()
+	}
+	return t
+}
+
+// 返回本地Cache
+func (t *TieredCache) Local() *Cache {
+	return t.local
+}
+
+// 本地未命中时从远端读取并写入本地, 并发未命中时远端只读取一次
+func (t *TieredCache) Get(ctx context.Context, k string) (interface{}, bool, error) {
+	if v, ok := t.local.Get(k); ok {
+		return v, true, nil
+	}
+	// 以*found区分远端不存在和值为nil
+	type found struct{ v interface{} }
+	r, err := t.flight.do(k, func() (interface{}, error) {
+		if v, ok := t.local.get(k); ok {
+			return &found{v}, nil
+		}
+		v, ok, err := t.backendGet(ctx, k)
+		if err != nil || !ok {
+			return nil, err
+		}
+		t.local.Set(k, v, t.opts.localTTL)
+		return &found{v}, nil
+	})
+	if err != nil || r == nil {
+		return nil, false, err
+	}
+	return r.(*found).v, true, nil
+}
+
+func (t *TieredCache) Set(ctx context.Context, k string, v interface{}, d time.Duration) error {
+	if t.opts.mode == WriteBehind {
+		if t.isClosed() {
+			return ErrClosed
+		}
+		if err := t.local.SetCtx(ctx, k, v, d); err != nil {
+			return err
+		}
+		return t.enqueue(ctx, tieredWrite{k: k, v: v, d: d})
+	}
+	if err := t.backendSet(ctx, k, v, d); err != nil {
+		return err
+	}
+	return t.local.SetCtx(ctx, k, v, d)
+}
+
+func (t *TieredCache) Delete(ctx context.Context, k string) error {
+	t.local.Delete(k)
+	if t.opts.mode == WriteBehind {
+		return t.enqueue(ctx, tieredWrite{k: k, delete: true})
+	}
+	return t.backendDelete(ctx, k)
+}
+
+func (t *TieredCache) isClosed() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.closed
+}
+
+func (t *TieredCache) enqueue(ctx context.Context, w tieredWrite) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return ErrClosed
+	}
+	select {
+	case t.queue <- w:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *TieredCache) writeLoop() {
+	defer close(t.done)
+	for w := range t.queue {
+		var err error
+		if w.delete {
+			err = t.backendDelete(context.Background(), w.k)
+		} else {
+			err = t.backendSet(context.Background(), w.k, w.v, w.d)
+		}
+		if err != nil && t.opts.onError != nil {
+			t.opts.onError(w.k, err)
+		}
+	}
+}
+
+// 等待异步写入全部完成后返回, 不关闭本地Cache
+func (t *TieredCache) Close() error {
+	t.closeOnce.Do(func() {
+		if t.queue == nil {
+			return
+		}
+		t.mu.Lock()
+		t.closed = true
+		close(t.queue)
+		t.mu.Unlock()
+		<-t.done
+	})
+	return nil
+}
@@ -0,0 +1,62 @@
+package fcache
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/fredalxin/fcache"
+
+// 为loader调用, 保存和加载文件以及TieredCache的远端读写创建span, 默认不创建
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		if tp == nil {
+			o.tracer = nil
+			return
+		}
+		o.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// 没有设置TracerProvider时返回ctx和不记录的span
+func (c *Cache) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	t := c.conf().tracer
+	if t == nil {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	return t.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *TieredCache) backendGet(ctx context.Context, k string) (interface{}, bool, error) {
+	ctx, span := t.local.startSpan(ctx, "fcache.backend.get", attribute.String("fcache.key", k))
+	v, ok, err := t.backend.Get(ctx, k)
+	span.SetAttributes(attribute.Bool("fcache.found", ok))
+	endSpan(span, err)
+	return v, ok, err
+}
+
+func (t *TieredCache) backendSet(ctx context.Context, k string, v interface{}, d time.Duration) error {
+	ctx, span := t.local.startSpan(ctx, "fcache.backend.set", attribute.String("fcache.key", k))
+	err := t.backend.Set(ctx, k, v, d)
+	endSpan(span, err)
+	return err
+}
+
+func (t *TieredCache) backendDelete(ctx context.Context, k string) error {
+	ctx, span := t.local.startSpan(ctx, "fcache.backend.delete", attribute.String("fcache.key", k))
+	err := t.backend.Delete(ctx, k)
+	endSpan(span, err)
+	return err
+}
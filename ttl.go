@@ -0,0 +1,89 @@
+package fcache
+
+import "time"
+
+// 返回值及过期时间, 没有过期时间时返回零值time.Time
+func (c *Cache) GetWithExpiration(k string) (interface{}, time.Time, bool) {
+	s := c.shard(k)
+	s.mu.Lock()
+	v, ok := s.get(k)
+	item := s.items[k]
+	s.unlock()
+	c.recordGet(ok)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	if item.Expiration == 0 {
+		return v, time.Time{}, true
+	}
+	return v, time.Unix(0, item.Expiration), true
+}
+
+// 返回剩余存活时间, 没有过期时间时返回NoExpiration
+func (c *Cache) TTL(k string) (time.Duration, bool) {
+	s := c.shard(k)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items[k]
+	if !ok || s.pending(k) || c.expired(item) {
+		return 0, false
+	}
+	if item.Expiration == 0 {
+		return NoExpiration, true
+	}
+	return c.remaining(item.Expiration), true
+}
+
+// 修改有效条目的过期时间, 条目不存在或已过期时返回false
+func (c *Cache) updateExpiration(k string, f func(item *Item, now time.Time)) bool {
+	if c.writable() != nil {
+		return false
+	}
+	s := c.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[k]
+	if !ok || s.pending(k) || c.expired(item) {
+		return false
+	}
+	now := c.now()
+	f(&item, now)
+	c.limitExpiration(&item, now.UnixNano())
+	s.items[k] = item
+	s.publish(k, item)
+	s.policy.touch(k)
+	s.trackExpiration(k, item.Expiration)
+	if a := s.c.aof.Load(); a != nil {
+		a.append(aofSet, k, item)
+	}
+	if d := s.c.disk; d != nil {
+		d.append(aofSet, k, item)
+	}
+	if m := s.c.mapped; m != nil {
+		m.shadow(k)
+	}
+	return true
+}
+
+// 按写入时的存活时间重新计算过期时间
+func (c *Cache) Touch(k string) bool {
+	return c.updateExpiration(k, func(item *Item, now time.Time) {
+		if item.TTL > 0 {
+			item.Expiration = now.Add(item.TTL).UnixNano()
+		}
+	})
+}
+
+// 设置新的存活时间, d的含义与Set相同
+func (c *Cache) Expire(k string, d time.Duration) bool {
+	return c.updateExpiration(k, func(item *Item, now time.Time) {
+		item.Expiration, item.TTL = c.expiration(d, now)
+	})
+}
+
+// 去掉过期时间
+func (c *Cache) Persist(k string) bool {
+	return c.updateExpiration(k, func(item *Item, now time.Time) {
+		item.Expiration, item.TTL = 0, 0
+	})
+}
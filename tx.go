@@ -0,0 +1,170 @@
+package fcache
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// 冲突时重新执行的次数上限
+const maxTxAttempts = 64
+
+// 事务内的读写, 写入在提交前对其他goroutine不可见
+type Txn struct {
+	c      *Cache
+	reads  map[string]txRead
+	writes map[string]txWrite
+}
+
+// 读到的条目的身份, 提交时用于检查是否被其他goroutine修改
+type txRead struct {
+	exists  bool
+	version uint64
+	created int64
+}
+
+type txWrite struct {
+	v      interface{}
+	d      time.Duration
+	delete bool
+}
+
+// 优先返回本事务内的写入
+func (tx *Txn) Get(k string) (interface{}, bool) {
+	if w, ok := tx.writes[k]; ok {
+		if w.delete {
+			return nil, false
+		}
+		return w.v, true
+	}
+	s := tx.c.shard(k)
+	s.mu.RLock()
+	item, ok := s.live(k)
+	s.mu.RUnlock()
+	if _, seen := tx.reads[k]; !seen {
+		tx.reads[k] = txRead{exists: ok, version: item.Version, created: item.Created}
+	}
+	if !ok {
+		return nil, false
+	}
+	return tx.c.copyValue(unpack(item.Object)), true
+}
+
+func (tx *Txn) Set(k string, v interface{}, d time.Duration) {
+	tx.writes[k] = txWrite{v: v, d: d}
+}
+
+func (tx *Txn) Delete(k string) {
+	tx.writes[k] = txWrite{delete: true}
+}
+
+// 执行f并原子地提交其中的写入: 其他goroutine要么看到全部写入, 要么一个也看不到
+// f返回错误时丢弃所有写入; f读取过的key在提交前被修改时会重新执行f, 因此f可能被执行多次且不应有副作用
+// 提交时不等待容量空间, 超出条目数上限时按淘汰策略淘汰, RejectOnFull和BlockOnFull策略下返回ErrCacheFull
+func (c *Cache) Tx(f func(tx *Txn) error) error {
+	for i := 0; i < maxTxAttempts; i++ {
+		if err := c.writable(); err != nil {
+			return err
+		}
+		tx := &Txn{c: c, reads: map[string]txRead{}, writes: map[string]txWrite{}}
+		if err := f(tx); err != nil {
+			return err
+		}
+		ok, err := c.commit(tx)
+		if ok || err != nil {
+			return err
+		}
+	}
+	return ErrConflict
+}
+
+// 按下标顺序锁住涉及的shard, 校验读取的key后写入; 返回false表示有冲突
+func (c *Cache) commit(tx *Txn) (bool, error) {
+	if len(tx.writes) == 0 {
+		return true, nil
+	}
+	for k, w := range tx.writes {
+		if w.delete {
+			continue
+		}
+		if err := c.checkTTL(w.d); err != nil {
+			return false, err
+		}
+		w.d = c.inheritTTL(k, w.d)
+		tx.writes[k] = w
+	}
+	var idx []int
+	seen := map[int]bool{}
+	for _, m := range []map[string]bool{tx.readKeys(), tx.writeKeys()} {
+		for k := range m {
+			if i := c.shardIndex(k); !seen[i] {
+				seen[i] = true
+				idx = append(idx, i)
+			}
+		}
+	}
+	sort.Ints(idx)
+	for _, i := range idx {
+		c.shards[i].mu.Lock()
+	}
+	ok, err := c.applyTx(tx)
+	notify := make([]func(), len(idx))
+	for j, i := range idx {
+		notify[j] = c.shards[i].unlockLater()
+	}
+	for _, f := range notify {
+		f()
+	}
+	c.shrink()
+	return ok, err
+}
+
+func (tx *Txn) readKeys() map[string]bool {
+	m := make(map[string]bool, len(tx.reads))
+	for k := range tx.reads {
+		m[k] = true
+	}
+	return m
+}
+
+func (tx *Txn) writeKeys() map[string]bool {
+	m := make(map[string]bool, len(tx.writes))
+	for k := range tx.writes {
+		m[k] = true
+	}
+	return m
+}
+
+// 调用时需持有所有涉及的shard的写锁
+func (c *Cache) applyTx(tx *Txn) (bool, error) {
+	for k, r := range tx.reads {
+		item, ok := c.shard(k).live(k)
+		if ok != r.exists || (ok && (item.Version != r.version || item.Created != r.created)) {
+			return false, nil
+		}
+	}
+	cfg := c.conf()
+	if cfg.maxEntries > 0 && cfg.overflow != EvictOnFull {
+		added := 0
+		for k, w := range tx.writes {
+			if _, ok := c.shard(k).items[k]; !ok && !w.delete {
+				added++
+			}
+		}
+		if added > 0 && atomic.LoadInt64(&c.count)+int64(added) > int64(cfg.maxEntries) {
+			return false, fmt.Errorf("%w: transaction adds %d keys", ErrCacheFull, added)
+		}
+	}
+	for k, w := range tx.writes {
+		s := c.shard(k)
+		if !w.delete {
+			s.set(k, w.v, w.d)
+			continue
+		}
+		if s.deleteKey(k) {
+			atomic.AddUint64(&c.stats.deletes, 1)
+		}
+	}
+	return true, nil
+}
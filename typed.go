@@ -0,0 +1,47 @@
+package fcache
+
+import "time"
+
+// 固定值类型的Cache视图, 省去类型断言
+type Typed[V any] struct {
+	c *Cache
+}
+
+func NewTyped[V any](c *Cache) *Typed[V] {
+	return &Typed[V]{c: c}
+}
+
+// 返回底层的Cache
+func (t *Typed[V]) Cache() *Cache {
+	return t.c
+}
+
+func (t *Typed[V]) Set(k string, v V, d time.Duration) {
+	t.c.Set(k, v, d)
+}
+
+func (t *Typed[V]) Add(k string, v V, d time.Duration) error {
+	return t.c.Add(k, v, d)
+}
+
+func (t *Typed[V]) Update(k string, v V, d time.Duration) error {
+	return t.c.Update(k, v, d)
+}
+
+// 值不是V类型时返回零值和false
+func (t *Typed[V]) Get(k string) (V, bool) {
+	var zero V
+	v, ok := t.c.Get(k)
+	if !ok {
+		return zero, false
+	}
+	tv, ok := v.(V)
+	if !ok {
+		return zero, false
+	}
+	return tv, true
+}
+
+func (t *Typed[V]) Delete(k string) {
+	t.c.Delete(k)
+}
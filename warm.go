@@ -0,0 +1,112 @@
+package fcache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 加载k的值, 返回的值以返回的存活时间写入
+type LoaderFunc func(ctx context.Context, k string) (interface{}, time.Duration, error)
+
+type warmOptions struct {
+	progress func(done, total int)
+}
+
+type WarmOption func(o *warmOptions)
+
+// 每加载完一个key调用一次f, done为已完成的数量, 包括失败和跳过的key; f可能被并发调用
+func WithWarmProgress(f func(done, total int)) WarmOption {
+	return func(o *warmOptions) { o.progress = f }
+}
+
+// Warm中加载失败的key及其错误
+type WarmError struct {
+	Errors map[string]error
+}
+
+func (e *WarmError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for k := range e.Errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 3 {
+		keys = keys[:3]
+	}
+	msgs := make([]string, len(keys))
+	for i, k := range keys {
+		msgs[i] = fmt.Sprintf("%s: %v", k, e.Errors[k])
+	}
+	s := fmt.Sprintf("fcache: warm failed for %d keys: %s", len(e.Errors), strings.Join(msgs, "; "))
+	if len(e.Errors) > len(keys) {
+		s += "; ..."
+	}
+	return s
+}
+
+// 供errors.Is和errors.As逐个匹配
+func (e *WarmError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// 以最多concurrency个并发调用loader预先加载keys, 已存在的key跳过
+// 加载失败不影响其余key, 全部完成后以*WarmError返回所有失败的key; ctx结束时未开始加载的key记为ctx的错误
+func (c *Cache) Warm(ctx context.Context, keys []string, loader LoaderFunc, concurrency int, opts ...WarmOption) error {
+	var o warmOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var (
+		mu   sync.Mutex
+		errs = map[string]error{}
+		done int64
+		wg   sync.WaitGroup
+	)
+	finish := func(k string, err error) {
+		if err != nil {
+			mu.Lock()
+			errs[k] = err
+			mu.Unlock()
+		}
+		if o.progress != nil {
+			o.progress(int(atomic.AddInt64(&done, 1)), len(keys))
+		}
+	}
+	sem := make(chan struct{}, concurrency)
+	for _, k := range keys {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			finish(k, err)
+			continue
+		}
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			_, err := c.compute(ctx, k, false, func(ctx context.Context) (interface{}, time.Duration, error) {
+				return loader(ctx, k)
+			})
+			finish(k, err)
+		}(k)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return &WarmError{Errors: errs}
+	}
+	return nil
+}
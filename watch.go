@@ -0,0 +1,125 @@
+package fcache
+
+import (
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+type EventType int
+
+const (
+	EventSet EventType = iota
+	EventDelete
+	EventExpire
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	}
+	return "unknown"
+}
+
+type Event struct {
+	Type  EventType
+	Key   string
+	Value interface{}
+	// 删除的原因, 只对EventDelete和EventExpire有效
+	Reason Reason
+}
+
+// 每个订阅的缓冲大小, 订阅者消费不及时缓冲满后新的事件会被丢弃
+const watchBuffer = 128
+
+type watcher struct {
+	re   *regexp.Regexp
+	ch   chan Event
+	once sync.Once
+}
+
+type watchers struct {
+	mu   sync.RWMutex
+	n    int32
+	list []*watcher
+}
+
+// 订阅匹配glob的key的写入, 删除和过期事件, pattern为空时订阅所有key
+// 返回的函数取消订阅并关闭channel; 缓存关闭时channel也会被关闭
+func (c *Cache) Subscribe(pattern string) (<-chan Event, func(), error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	re, err := compileGlob(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.closed() {
+		return nil, nil, ErrClosed
+	}
+	w := &watcher{re: re, ch: make(chan Event, watchBuffer)}
+	c.watch.mu.Lock()
+	c.watch.list = append(c.watch.list, w)
+	atomic.StoreInt32(&c.watch.n, int32(len(c.watch.list)))
+	c.watch.mu.Unlock()
+	return w.ch, func() { c.watch.remove(w) }, nil
+}
+
+func (ws *watchers) remove(w *watcher) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for i, x := range ws.list {
+		if x == w {
+			ws.list = append(ws.list[:i], ws.list[i+1:]...)
+			break
+		}
+	}
+	atomic.StoreInt32(&ws.n, int32(len(ws.list)))
+	w.once.Do(func() { close(w.ch) })
+}
+
+func (ws *watchers) closeAll() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for _, w := range ws.list {
+		w.once.Do(func() { close(w.ch) })
+	}
+	ws.list = nil
+	atomic.StoreInt32(&ws.n, 0)
+}
+
+// 在锁外调用, 不阻塞写入
+func (ws *watchers) publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	for _, e := range events {
+		for _, w := range ws.list {
+			if !w.re.MatchString(e.Key) {
+				continue
+			}
+			select {
+			case w.ch <- e:
+			default:
+			}
+		}
+	}
+}
+
+// 调用时需持有写锁, 事件在unlock时发布
+func (s *shard) event(t EventType, k string, v interface{}, reason Reason) {
+	if atomic.LoadInt32(&s.c.watch.n) == 0 {
+		return
+	}
+	if t == EventDelete && reason == Expired {
+		t = EventExpire
+	}
+	s.events = append(s.events, Event{Type: t, Key: k, Value: unpack(v), Reason: reason})
+}
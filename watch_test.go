@@ -0,0 +1,43 @@
+package fcache
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c := New(WithClock(clock), WithGCInterval(0))
+	ch, cancel, err := c.Subscribe("user:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("user:1", 1, NoExpiration)
+	c.Set("other", 1, NoExpiration)
+	c.Delete("user:1")
+	c.Set("user:2", 2, time.Second)
+	clock.Advance(2 * time.Second)
+	c.RunGC()
+	cancel()
+
+	var got []Event
+	for e := range ch {
+		got = append(got, e)
+	}
+	want := []Event{
+		{Type: EventSet, Key: "user:1", Value: 1},
+		{Type: EventDelete, Key: "user:1", Value: 1, Reason: Deleted},
+		{Type: EventSet, Key: "user:2", Value: 2},
+		{Type: EventExpire, Key: "user:2", Value: 2, Reason: Expired},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events %+v, want %+v", got, want)
+	}
+
+	c.Close()
+	if _, _, err := c.Subscribe(""); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Subscribe after Close: err %v, want ErrClosed", err)
+	}
+}